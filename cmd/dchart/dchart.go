@@ -4,7 +4,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/ajstarks/dchart"
 	"github.com/ajstarks/deckgen"
@@ -20,42 +22,89 @@ Chart Types
 -bar        true                      bar chart
 -wbar       false                     word bar chart
 -hbar       false                     horizontal bar chart
+-hist       false                     histogram of the values
+-heatmap    false                     heatmap of row, column, value triples
+-calendar   false                     calendar heatmap of daily values
+-box        false                     box plot of the values of each label
+-violin     false                     violin plot of the values of each label
 -donut      false                     donut chart
 -dot        false                     dot chart
 -lego       false                     lego chart
 -line       false                     line chart
+-ohlc       false                     open-high-low-close chart
 -pgrid      false                     proportional grid
 -pmap       false                     proportional map
+-treemap    false                     treemap (two-dimensional proportional map)
 -bowtie     false                     bowtie chart
+-candle     false                     candlestick chart (open,high,low,close)
 -fan        false                     fan chart
 -radial     false                     radial chart
 -scatter    false                     scatter chart
 -slope      false                     slope chart
+-stack      false                     stacked bar or volume chart
+-stack100   false                     stacked chart normalized to 100%
 -vol        false                     volume (area) chart
+-waterfall  false                     waterfall (bridge) chart of changes, with subtotal and total rows
+-xy         false                     x/y scatter chart (bubbles with a size column)
 
 
 Chart Elements
 .......................................................................
 -csv        false                     read CSV files
+-cumulative false                     cumulative histogram
+-density    false                     histogram of densities, not counts
 -frame      false                     show a colored frame
 -fulldeck   true                      generate full deck markup
+-o          deck                      output format (deck, dsh, svg, html, png)
 -grid       false                     show gridlines on the y axis
+-group      false                     treemap groups: read group, label, value
+-legend     false                     show a legend
+-layout     ""                        small multiples: rows x cols of charts per slide
+-sharey     false                     share the value scale across a layout
+-normal     false                     show the normal curve on a histogram
 -note       true                      show annotations
+-ohlcvol    false                     show volume below candlestick or OHLC charts (the series named volume)
 -pct        false                     show computed percentage
--rline      false                     show a regression line
+-rline      false                     show a regression line (one per series)
+-rband      false                     show the 95% confidence band of the regression line
+-rstats     false                     show the regression equation, R² and n
 -solidpmap  false                     show solid pmap colors
+-strict     false                     stop at the first data error
+-tsvnote    false                     read the third of three TSV columns as the annotation, even if numeric
 -spokes     false                     show spokes in radial chart
+-time       false                     time axis (labels are times)
+-timefmt    2006-01-02                time label layout
 -title      true                      show the title
 -val        true                      show values
 -xlast      false                     show the last x label
 -xstagger   false                     stagger x axis labels
 -yaxis      false                     show a y axis
 -chartitle  override title in data    specify the title
--datacond   low,high,colors           conditional data colors
+-datacond   test:color;...            conditional data colors, in order: <v, <=v, >v, >=v, =v, !=v, low..high,
+                                      label~regexp, note~regexp (for example "<0:red; 0..50:orange; >=50:green"),
+                                      \; is a semicolon in a regexp
+-negcolor   ""                        color of negative values not colored by a condition
 -hline      value,label2              label horizontal line at value
+-ref        kind:at:color:style:label reference line or band (repeatable): value:v, label:l, band:low..high
+                                      (styles are solid, dash or dot)
+-overlay    kind:params:color:width   trend overlays: sma:n, ema:n, loess:span, poly:degree, bollinger:n:k
+-legendpos  top                       legend position (top, bottom, left, right, tl, tr, bl, br)
+-legendorient h=rows, v=columns       legend orientation
 -valpos     t=top, b=bottom, m=middle value position
 -xlabel     default=1, 0 to suppress  x axis label interval
+-xrange     min,max,step              specify the x axis range (x/y charts)
 -yrange     min,max.step              specify the y axis label range
+-ylog       false                     logarithmic y (value) scale
+-logbase    10                        base of the logarithmic scale
+
+
+Data Transformations (applied in this order)
+.......................................................................
+-filter     low,high                  keep values from low to high (either may be empty)
+-groupby    ""                        combine data with the same label (sum, mean, count, min, max)
+-sort       ""                        sort by label or value, optionally desc (for example value,desc)
+-topn       0 (all)                   keep the top n values, adding the rest as Other
+-cumsum     false                     cumulative sums of the values
 
 
 Position and Scaling
@@ -73,9 +122,12 @@ Measures and Attributes
 .......................................................................
 -bgcolor    white                     background color
 -barwidth   computed from data size   barwidth
+-bins       sturges                   histogram bins (sturges, scott, fd, or a count)
+-binwidth   computed from bins        histogram bin width
 -color      lightsteelblue            data color
 -csvcol     labe1,label2              specify csv columns
 -datafmt    %.1f                      format for values (%f or %,)
+-downcolor  rgb(200,0,0)              color of falling prices and decreases
 -dmin       false                     use data minimum, not zero
 -framecolor rgb(127,127,127)          frame color
 -lcolor     rgb(75,75,75)             label color
@@ -86,12 +138,36 @@ Measures and Attributes
 -psize      30                        diameter of the donut
 -pwidth     30                        width of the donut or pmap
 -rlcolor    rgb(127,0,0)              regression line color
+-scolors    default palette           space-separated series colors
+-series     from CSV header           comma-separated series names
 -textsize   1.50                      text size
+-upcolor    rgb(0,128,0)              color of rising prices and increases
 -xlabrot    0                         xlabel rotation (deg.)
 -vcolor     rgb(127,0,0)              value color
 -volop      50                        volume opacity %
 `
 
+// output is the output format, layout is the small multiples grid
+var output, layout string
+
+// references collects repeated -ref flags, separated by semicolons
+type references struct{ specs *string }
+
+func (r references) String() string {
+	if r.specs == nil {
+		return ""
+	}
+	return *r.specs
+}
+
+func (r references) Set(spec string) error {
+	if len(*r.specs) > 0 {
+		*r.specs += ";"
+	}
+	*r.specs += spec
+	return nil
+}
+
 func printusage() {
 	fmt.Fprintln(flag.CommandLine.Output(), usageMsg)
 }
@@ -109,6 +185,7 @@ func cmdflags() dchart.Settings {
 	flag.Float64Var(&chart.Bottom, "bottom", 30.0, "bottom of the plot")
 	flag.Float64Var(&chart.LineSpacing, "ls", 2.4, "ls")
 	flag.Float64Var(&chart.BarWidth, "barwidth", 0, "barwidth")
+	flag.Float64Var(&chart.BinWidth, "binwidth", 0, "histogram bin width")
 	flag.Float64Var(&chart.UserMin, "min", -1, "minimum")
 	flag.Float64Var(&chart.UserMax, "max", -1, "maximum")
 	flag.Float64Var(&chart.PSize, "psize", 40.0, "size of the donut")
@@ -116,6 +193,7 @@ func cmdflags() dchart.Settings {
 	flag.Float64Var(&chart.LineWidth, "linewidth", 0.2, "width of line for line charts")
 	flag.Float64Var(&chart.VolumeOpacity, "volop", 50, "volume opacity")
 	flag.Float64Var(&chart.XLabelRotation, "xlabrot", 0, "xlabel rotation (degrees)")
+	flag.Float64Var(&chart.LogBase, "logbase", 10, "base of the logarithmic scale")
 	flag.IntVar(&chart.XLabelInterval, "xlabel", 1, "x axis label interval (show every n labels, 0 to show no labels)")
 	flag.IntVar(&chart.PMapLength, "pmlen", 20, "pmap label length")
 	flag.StringVar(&chart.Boundary, "bounds", "", "chart boundary (left,right,top,bottom)")
@@ -128,29 +206,54 @@ func cmdflags() dchart.Settings {
 	flag.BoolVar(&chart.ShowPMap, "pmap", false, "show a proportional map")
 	flag.BoolVar(&chart.ShowLine, "line", false, "show a line chart")
 	flag.BoolVar(&chart.ShowHBar, "hbar", false, "show a horizontal bar chart")
+	flag.BoolVar(&chart.ShowHistogram, "hist", false, "show a histogram")
+	flag.BoolVar(&chart.ShowBox, "box", false, "show a box plot")
+	flag.BoolVar(&chart.ShowViolin, "violin", false, "show a violin plot")
+	flag.BoolVar(&chart.ShowWaterfall, "waterfall", false, "show a waterfall chart")
+	flag.BoolVar(&chart.ShowHeatmap, "heatmap", false, "show a heatmap")
+	flag.BoolVar(&chart.ShowCalendar, "calendar", false, "show a calendar heatmap")
+	flag.BoolVar(&chart.ShowTreemap, "treemap", false, "show a treemap")
+	flag.BoolVar(&chart.Grouped, "group", false, "treemap groups: read group, label, value")
+	flag.BoolVar(&chart.ShowNormal, "normal", false, "show the normal curve on a histogram")
+	flag.BoolVar(&chart.Density, "density", false, "histogram of densities")
+	flag.BoolVar(&chart.Cumulative, "cumulative", false, "cumulative histogram")
 	flag.BoolVar(&chart.ShowValues, "val", true, "show data values")
 	flag.BoolVar(&chart.ShowAxis, "yaxis", false, "show y axis")
+	flag.BoolVar(&chart.LogScale, "ylog", false, "logarithmic y (value) scale")
 	flag.BoolVar(&chart.ShowSlope, "slope", false, "show a slope graph")
 	flag.BoolVar(&chart.ShowTitle, "title", true, "show title")
 	flag.BoolVar(&chart.ShowGrid, "grid", false, "show y axis grid")
+	flag.BoolVar(&chart.ShowLegend, "legend", false, "show a legend")
+	flag.BoolVar(&chart.SharedScale, "sharey", false, "share the value scale across a layout")
 	flag.BoolVar(&chart.ShowScatter, "scatter", false, "show scatter chart")
+	flag.BoolVar(&chart.ShowXY, "xy", false, "show x/y scatter chart")
 	flag.BoolVar(&chart.ShowRadial, "radial", false, "show a radial chart")
 	flag.BoolVar(&chart.ShowSpokes, "spokes", false, "show spokes on radial charts")
 	flag.BoolVar(&chart.ShowPGrid, "pgrid", false, "show proportional grid")
 	flag.BoolVar(&chart.ShowLego, "lego", false, "show lego chart")
 	flag.BoolVar(&chart.ShowBowtie, "bowtie", false, "show bowtie chart")
 	flag.BoolVar(&chart.ShowFan, "fan", false, "show fan chart")
+	flag.BoolVar(&chart.ShowCandle, "candle", false, "show candlestick chart")
+	flag.BoolVar(&chart.ShowOHLC, "ohlc", false, "show open-high-low-close chart")
+	flag.BoolVar(&chart.ShowCandleVolume, "ohlcvol", false, "show volume below candlestick or OHLC charts")
 	flag.BoolVar(&chart.ShowNote, "note", true, "show annotations")
 	flag.BoolVar(&chart.ShowFrame, "frame", false, "show frame")
 	flag.BoolVar(&chart.ShowRegressionLine, "rline", false, "show regression line")
+	flag.BoolVar(&chart.ShowRegressionBand, "rband", false, "show the confidence band of the regression line")
+	flag.BoolVar(&chart.ShowRegressionStats, "rstats", false, "show regression statistics")
 	flag.BoolVar(&chart.ShowXLast, "xlast", false, "show the last label")
 	flag.BoolVar(&chart.ShowXstagger, "xstagger", false, "stagger x axis labels")
+	flag.BoolVar(&chart.ShowTimeAxis, "time", false, "time axis (labels are times)")
 	flag.BoolVar(&chart.FullDeck, "fulldeck", true, "generate full markup")
 	flag.BoolVar(&chart.DataMinimum, "dmin", false, "zero minimum")
 	flag.BoolVar(&chart.ReadCSV, "csv", false, "read CSV data")
+	flag.BoolVar(&chart.StrictData, "strict", false, "stop at the first data error")
+	flag.BoolVar(&chart.TSVNote, "tsvnote", false, "read the third of three TSV columns as the annotation")
 	flag.BoolVar(&chart.ShowWBar, "wbar", false, "show word bar chart")
 	flag.BoolVar(&chart.ShowPercentage, "pct", false, "show computed percentages with values")
 	flag.BoolVar(&chart.SolidPMap, "solidpmap", false, "solid pmap colors")
+	flag.BoolVar(&chart.ShowStack, "stack", false, "stack multiple series")
+	flag.BoolVar(&chart.StackPercent, "stack100", false, "stack multiple series, normalized to 100%")
 
 	// Attributes
 	flag.StringVar(&chart.ChartTitle, "chartitle", "", "specify the title (overiding title in the data)")
@@ -160,13 +263,32 @@ func cmdflags() dchart.Settings {
 	flag.StringVar(&chart.DataColor, "color", "lightsteelblue", "data color")
 	flag.StringVar(&chart.ValueColor, "vcolor", "rgb(127,0,0)", "value color")
 	flag.StringVar(&chart.RegressionLineColor, "rlcolor", "rgb(127,0,0)", "regression line color")
+	flag.StringVar(&chart.SeriesColors, "scolors", "", "space-separated series colors")
+	flag.StringVar(&chart.SeriesNames, "series", "", "comma-separated series names")
 	flag.StringVar(&chart.FrameColor, "framecolor", "rgb(127,127,127)", "framecolor")
 	flag.StringVar(&chart.BackgroundColor, "bgcolor", "white", "background color")
+	flag.StringVar(&chart.UpColor, "upcolor", "rgb(0,128,0)", "color of rising prices")
+	flag.StringVar(&chart.DownColor, "downcolor", "rgb(200,0,0)", "color of falling prices")
 	flag.StringVar(&chart.DataFmt, "datafmt", dchart.Defaultfmt, "data format")
 	flag.StringVar(&chart.YAxisR, "yrange", "", "y-axis range (min,max,step)")
+	flag.StringVar(&chart.XAxisR, "xrange", "", "x-axis range (min,max,step)")
+	flag.StringVar(&chart.TimeFormat, "timefmt", dchart.Defaulttimefmt, "time label layout")
 	flag.StringVar(&chart.HLine, "hline", "", "horizontal line value,label")
+	flag.StringVar(&chart.LegendPosition, "legendpos", "top", "legend position (top, bottom, left, right, tl, tr, bl, br)")
+	flag.StringVar(&chart.LegendOrientation, "legendorient", "", "legend orientation (h=rows, v=columns)")
 	flag.StringVar(&chart.NoteLocation, "noteloc", "c", "note location (c-center, r-right aligned, l-left aligned)")
-	flag.StringVar(&chart.DataCondition, "datacond", "", "data condition: low,high,color")
+	flag.StringVar(&chart.DataCondition, "datacond", "", "conditional colors: test:color rules, separated by semicolons")
+	flag.StringVar(&chart.NegativeColor, "negcolor", "", "color of negative values")
+	flag.Var(references{&chart.References}, "ref", "reference line or band kind:position:color:style:label (repeatable)")
+	flag.StringVar(&chart.Overlays, "overlay", "", "trend overlays (sma, ema, loess, poly, bollinger)")
+	flag.StringVar(&output, "o", "deck", "output format (deck, dsh, svg, html, png)")
+	flag.StringVar(&chart.BinRule, "bins", "sturges", "histogram bins (sturges, scott, fd, or a count)")
+	flag.StringVar(&layout, "layout", "", "small multiples layout (rows x cols)")
+	flag.StringVar(&chart.Filter, "filter", "", "keep values from low to high (low,high)")
+	flag.StringVar(&chart.Aggregate, "groupby", "", "combine data with the same label (sum, mean, count, min, max)")
+	flag.StringVar(&chart.SortBy, "sort", "", "sort by label or value (label|value[,asc|desc])")
+	flag.IntVar(&chart.TopN, "topn", 0, "keep the top n values, adding the rest as Other")
+	flag.BoolVar(&chart.CumulativeSum, "cumsum", false, "cumulative sums of the values")
 	flag.Usage = printusage
 	flag.Parse()
 	if len(chart.Boundary) > 0 {
@@ -175,28 +297,97 @@ func cmdflags() dchart.Settings {
 
 	return chart
 }
+
+// renderer returns the renderer for the output format
+func renderer(format string, chart dchart.Settings) dchart.Renderer {
+	switch format {
+	case "deck", "xml":
+		return deckgen.NewSlides(os.Stdout, 0, 0)
+	case "dsh", "decksh":
+		return dchart.NewDecksh(os.Stdout)
+	case "svg":
+		return dchart.NewSVG(os.Stdout, chart.CanvasWidth, chart.CanvasHeight)
+	case "html":
+		return dchart.NewHTML(os.Stdout, chart.CanvasWidth, chart.CanvasHeight)
+	case "png":
+		return dchart.NewPNG(os.Stdout, chart.CanvasWidth, chart.CanvasHeight)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: unknown output format\n", format)
+		os.Exit(1)
+	}
+	return nil
+}
+
+// generate makes a chart from the named input, reporting warnings and errors
+func generate(chart *dchart.Settings, deck dchart.Renderer, name string, r *os.File) {
+	report(chart, name, chart.GenerateChart(deck, r))
+}
+
+// generatelayout makes small multiples from the named inputs, reporting warnings and errors
+func generatelayout(chart *dchart.Settings, deck dchart.Renderer, spec string, names []string) {
+	rows, cols, err := dchart.ParseLayout(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	files := make([]io.ReadCloser, len(names))
+	for i, name := range names {
+		r, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		files[i] = r
+	}
+	if len(names) == 0 {
+		files = append(files, os.Stdin)
+		names = append(names, "stdin")
+	}
+	report(chart, strings.Join(names, ","), chart.GenerateLayout(deck, rows, cols, files...))
+}
+
+// report shows the warnings, and exits on error
+func report(chart *dchart.Settings, name string, err error) {
+	for _, w := range chart.Warnings {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, w)
+	}
+	chart.Warnings = nil
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
 func main() {
 	chart := cmdflags()
-	fulldeck := chart.Flags.FullDeck
+	// standalone formats always have a complete document
+	fulldeck := chart.Flags.FullDeck || (output != "deck" && output != "dsh")
 
-	deck := deckgen.NewSlides(os.Stdout, 0, 0)
+	deck := renderer(output, chart)
 	if fulldeck {
 		deck.StartDeck()
 	}
-	if len(flag.Args()) > 0 {
+	switch {
+	case len(layout) > 0:
+		generatelayout(&chart, deck, layout, flag.Args())
+	case len(flag.Args()) > 0:
 		for _, file := range flag.Args() {
 			r, err := os.Open(file)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
 				os.Exit(1)
 			}
-			chart.GenerateChart(deck, r)
-			r.Close()
+			generate(&chart, deck, file, r)
 		}
-	} else {
-		chart.GenerateChart(deck, os.Stdin)
+	default:
+		generate(&chart, deck, "stdin", os.Stdin)
 	}
 	if fulldeck {
 		deck.EndDeck()
 	}
+	// renderers that write at the end of the deck report errors
+	if w, ok := deck.(interface{ Err() error }); ok && w.Err() != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", w.Err())
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,178 @@
+package dchart
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// colorrule colors data that passes a test of its value, label or annotation
+type colorrule struct {
+	test  func(d ChartData, v float64) bool
+	desc  string // the test, escaped for the legend
+	color string
+}
+
+// colorrules are ordered conditional color rules, and the color of negative values
+type colorrules struct {
+	rules    []colorrule
+	negative string
+}
+
+// colorrules parses the conditional colors (-datacond): rules separated by
+// semicolons, test:color, applied in order. Tests compare the value
+// (<v, <=v, >v, >=v, =v, !=v, or low..high inclusive), or match the label
+// or annotation with a regular expression (label~expr, note~expr).
+// For example "<0:red; 0..50:orange; >=50:green" or "label~^Q[34]:gray".
+// A semicolon in an expression is written \;.
+// A single low,high,color range is also accepted.
+// Negative values not colored by a rule are in the negative color (-negcolor).
+func (s *Settings) colorrules() (colorrules, error) {
+	c := colorrules{negative: s.Attributes.NegativeColor}
+	spec := s.Attributes.DataCondition
+	df := s.Attributes.DataFmt
+	if len(spec) > 0 && !strings.Contains(spec, ":") {
+		low, high, color, err := parsecondition(spec)
+		if err != nil {
+			return c, err
+		}
+		c.rules = append(c.rules, colorrule{
+			test:  func(_ ChartData, v float64) bool { return v >= low && v <= high },
+			desc:  dformat(df, low) + " to " + dformat(df, high),
+			color: color,
+		})
+		return c, nil
+	}
+	for _, r := range splitrules(spec) {
+		r = strings.TrimSpace(r)
+		if len(r) == 0 {
+			continue
+		}
+		i := strings.LastIndex(r, ":")
+		if i < 0 || i == len(r)-1 {
+			return c, fmt.Errorf("%q: conditions are test:color", r)
+		}
+		test, color := strings.TrimSpace(r[:i]), strings.TrimSpace(r[i+1:])
+		rule, err := parsetest(test, df)
+		if err != nil {
+			return c, fmt.Errorf("%q: %v", r, err)
+		}
+		rule.color = color
+		c.rules = append(c.rules, rule)
+	}
+	return c, nil
+}
+
+// splitrules splits conditional color rules at the semicolons
+// that are not escaped (\;), leaving escaped ones in the rule
+func splitrules(spec string) []string {
+	var rules []string
+	start := 0
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '\\':
+			i++
+		case ';':
+			rules = append(rules, spec[start:i])
+			start = i + 1
+		}
+	}
+	return append(rules, spec[start:])
+}
+
+// parsetest parses the test of a conditional color rule
+func parsetest(test, df string) (colorrule, error) {
+	for _, field := range []string{"label", "note"} {
+		expr, ok := strings.CutPrefix(test, field+"~")
+		if !ok {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return colorrule{}, err
+		}
+		match := func(d ChartData, _ float64) bool { return re.MatchString(d.Label) }
+		if field == "note" {
+			match = func(d ChartData, _ float64) bool { return re.MatchString(d.Note) }
+		}
+		desc := field + " ~ " + strings.ReplaceAll(expr, `\;`, ";")
+		return colorrule{test: match, desc: xmlesc(desc)}, nil
+	}
+	number := func(s string) (float64, error) {
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	if lo, hi, ok := strings.Cut(test, ".."); ok {
+		low, err := number(lo)
+		if err != nil {
+			return colorrule{}, err
+		}
+		high, err := number(hi)
+		if err != nil {
+			return colorrule{}, err
+		}
+		return colorrule{
+			test: func(_ ChartData, v float64) bool { return v >= low && v <= high },
+			desc: dformat(df, low) + " to " + dformat(df, high),
+		}, nil
+	}
+	// longer operators first
+	ops := []struct {
+		op, sym string
+		cmp     func(v, x float64) bool
+	}{
+		{"<=", "≤", func(v, x float64) bool { return v <= x }},
+		{">=", "≥", func(v, x float64) bool { return v >= x }},
+		{"!=", "≠", func(v, x float64) bool { return v != x }},
+		{"<", "<", func(v, x float64) bool { return v < x }},
+		{">", ">", func(v, x float64) bool { return v > x }},
+		{"=", "=", func(v, x float64) bool { return v == x }},
+	}
+	for _, o := range ops {
+		arg, ok := strings.CutPrefix(test, o.op)
+		if !ok {
+			continue
+		}
+		x, err := number(arg)
+		if err != nil {
+			return colorrule{}, err
+		}
+		cmp := o.cmp
+		return colorrule{
+			test: func(_ ChartData, v float64) bool { return cmp(v, x) },
+			desc: xmlesc(o.sym + " " + dformat(df, x)),
+		}, nil
+	}
+	return colorrule{}, fmt.Errorf("tests are <, <=, >, >=, =, != or low..high values, or label~ or note~ expressions")
+}
+
+// active reports whether there are any conditional colors
+func (c colorrules) active() bool {
+	return len(c.rules) > 0 || len(c.negative) > 0
+}
+
+// color returns the color of the value v of data d: the color of the first
+// matching rule, the negative color for negative values, or the default
+func (c colorrules) color(d ChartData, v float64, def string) string {
+	for _, r := range c.rules {
+		if r.test(d, v) {
+			return r.color
+		}
+	}
+	if v < 0 && len(c.negative) > 0 {
+		return c.negative
+	}
+	return def
+}
+
+// legenditems makes legend entries for the rules, and negative values
+func (c colorrules) legenditems() []legenditem {
+	var items []legenditem
+	for _, r := range c.rules {
+		items = append(items, legenditem{label: r.desc, color: r.color, opacity: 100})
+	}
+	if len(c.negative) > 0 {
+		items = append(items, legenditem{label: "negative", color: c.negative, opacity: 100})
+	}
+	return items
+}
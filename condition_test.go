@@ -0,0 +1,124 @@
+package dchart
+
+import "testing"
+
+func TestParsetest(t *testing.T) {
+	type probe struct {
+		d    ChartData
+		v    float64
+		want bool
+	}
+	value := func(v float64, want bool) probe { return probe{v: v, want: want} }
+	tests := []struct {
+		test   string
+		desc   string
+		probes []probe
+		err    bool
+	}{
+		{test: "<0", desc: "&lt; 0", probes: []probe{value(-1, true), value(0, false)}},
+		{test: "<=5", desc: "≤ 5", probes: []probe{value(5, true), value(5.1, false)}},
+		{test: ">5", desc: "&gt; 5", probes: []probe{value(6, true), value(5, false)}},
+		{test: ">= -2.5", desc: "≥ -2.5", probes: []probe{value(-2.5, true), value(-3, false)}},
+		{test: "=2", desc: "= 2", probes: []probe{value(2, true), value(3, false)}},
+		{test: "!=2", desc: "≠ 2", probes: []probe{value(3, true), value(2, false)}},
+		{test: "0..50", desc: "0 to 50", probes: []probe{value(0, true), value(50, true), value(50.5, false), value(-1, false)}},
+		{test: "-10..-5", desc: "-10 to -5", probes: []probe{value(-7, true), value(-4, false)}},
+		{
+			test: "label~^Q[34]", desc: "label ~ ^Q[34]",
+			probes: []probe{{d: ChartData{Label: "Q3"}, want: true}, {d: ChartData{Label: "Q1"}, want: false}},
+		},
+		{
+			test: "note~a;b", desc: "note ~ a;b",
+			probes: []probe{{d: ChartData{Note: "xa;b"}, want: true}, {d: ChartData{Label: "a;b"}, want: false}},
+		},
+		{test: "label~[", err: true},
+		{test: "<x", err: true},
+		{test: "1..", err: true},
+		{test: "~5", err: true},
+		{test: "", err: true},
+	}
+	for _, test := range tests {
+		rule, err := parsetest(test.test, Defaultfmt)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: no error", test.test)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.test, err)
+			continue
+		}
+		if rule.desc != test.desc {
+			t.Errorf("%q: description %q, want %q", test.test, rule.desc, test.desc)
+		}
+		for _, p := range test.probes {
+			if got := rule.test(p.d, p.v); got != p.want {
+				t.Errorf("%q: test of %v %+v is %v, want %v", test.test, p.v, p.d, got, p.want)
+			}
+		}
+	}
+}
+
+func TestColorrules(t *testing.T) {
+	type probe struct {
+		label string
+		v     float64
+		color string
+	}
+	tests := []struct {
+		spec, negative string
+		probes         []probe
+		err            bool
+	}{
+		{
+			spec:   "<0:red; 0..50:orange; >=50:green",
+			probes: []probe{{"a", -1, "red"}, {"a", 10, "orange"}, {"a", 50, "orange"}, {"a", 51, "green"}},
+		},
+		{
+			// the first matching rule wins, then the negative color
+			spec: "label~^x:gray; >5:green", negative: "red",
+			probes: []probe{{"x1", 9, "gray"}, {"y", 9, "green"}, {"y", -1, "red"}, {"y", 1, "default"}},
+		},
+		{
+			negative: "red",
+			probes:   []probe{{"a", -0.5, "red"}, {"a", 0, "default"}},
+		},
+		{
+			// escaped semicolons are in the expression, colons before the last are too
+			spec:   `label~^a\;b:c$:blue; <0:red`,
+			probes: []probe{{"a;b:c", 1, "blue"}, {"a", -1, "red"}, {"a;b", 1, "default"}},
+		},
+		{
+			spec:   "10,20,purple",
+			probes: []probe{{"a", 15, "purple"}, {"a", 25, "default"}},
+		},
+		{spec: "<0", err: true},
+		{spec: "<0:", err: true},
+		{spec: "?3:red", err: true},
+	}
+	for _, test := range tests {
+		s := NewChart("bar", 0, 0, 0, 0)
+		s.Attributes.DataCondition = test.spec
+		s.Attributes.NegativeColor = test.negative
+		rules, err := s.colorrules()
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: no error", test.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+			continue
+		}
+		if !rules.active() {
+			t.Errorf("%q: no active rules", test.spec)
+		}
+		for _, p := range test.probes {
+			if got := rules.color(ChartData{Label: p.label}, p.v, "default"); got != p.color {
+				t.Errorf("%q: color of %q %v is %s, want %s", test.spec, p.label, p.v, got, p.color)
+			}
+		}
+	}
+}
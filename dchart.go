@@ -5,72 +5,128 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"image/color"
 	"io"
 	"math"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ajstarks/deckgen"
+	"golang.org/x/image/colornames"
 )
 
-// ChartData defines the name,value pairs
+// ChartData defines the name,value pairs;
+// Values holds every value column of a row (Values[0] == Value)
 type ChartData struct {
-	label string
-	value float64
-	note  string
+	Label  string
+	Value  float64
+	Values []float64
+	Note   string
+}
+
+// NewChartData makes chart data with a label, annotation and one or more values;
+// each value is a series
+func NewChartData(label, note string, values ...float64) ChartData {
+	d := ChartData{Label: label, Note: note, Values: values}
+	if len(values) > 0 {
+		d.Value = values[0]
+	}
+	return d
 }
 
 // Flags define chart on/off switches
 type Flags struct {
+	Cumulative,
+	CumulativeSum,
 	DataMinimum,
+	Density,
 	FullDeck,
+	Grouped,
+	LogScale,
 	ReadCSV,
+	SharedScale,
 	ShowAxis,
 	ShowBar,
 	ShowBowtie,
+	ShowBox,
+	ShowCalendar,
+	ShowCandle,
+	ShowCandleVolume,
 	ShowDonut,
 	ShowDot,
 	ShowFan,
 	ShowFrame,
 	ShowGrid,
 	ShowHBar,
+	ShowHeatmap,
+	ShowHistogram,
+	ShowLegend,
 	ShowLine,
 	ShowLego,
+	ShowNormal,
 	ShowNote,
+	ShowOHLC,
 	ShowPercentage,
 	ShowPGrid,
 	ShowPMap,
 	ShowRadial,
+	ShowRegressionBand,
 	ShowRegressionLine,
+	ShowRegressionStats,
 	ShowScatter,
 	ShowSlope,
 	ShowSpokes,
+	ShowStack,
+	ShowTimeAxis,
 	ShowTitle,
+	ShowTreemap,
 	ShowValues,
+	ShowViolin,
 	ShowVolume,
+	ShowWaterfall,
 	ShowWBar,
 	ShowXLast,
 	ShowXstagger,
-	SolidPMap bool
+	ShowXY,
+	SolidPMap,
+	StackPercent,
+	StrictData,
+	TSVNote bool
 }
 
 // Attributes define chart attributes
 type Attributes struct {
 	BackgroundColor,
 	DataColor,
+	DownColor,
 	FrameColor,
 	LabelColor,
+	NegativeColor,
 	RegressionLineColor,
+	SeriesColors,
+	UpColor,
 	ValueColor,
+	Aggregate,
+	BinRule,
 	ChartTitle,
 	CSVCols,
 	DataCondition,
 	DataFmt,
+	Filter,
 	HLine,
+	LegendOrientation,
+	LegendPosition,
 	NoteLocation,
+	Overlays,
+	References,
+	SeriesNames,
+	SortBy,
+	TimeFormat,
 	ValuePosition,
+	XAxisR,
 	YAxisR string
 }
 
@@ -85,23 +141,28 @@ type Measures struct {
 	Bottom,
 	LineSpacing,
 	BarWidth,
+	BinWidth,
 	LineWidth,
 	PSize,
 	PWidth,
 	UserMin,
 	UserMax,
 	VolumeOpacity,
+	LogBase,
 	XLabelRotation float64
 	Boundary string
 	XLabelInterval,
-	PMapLength int
+	PMapLength,
+	TopN int
 }
 
-// Settings is a collection of all chart settings
+// Settings is a collection of all chart settings.
+// Warnings collects the input problems found while generating charts in lenient mode
 type Settings struct {
 	Flags
 	Attributes
 	Measures
+	Warnings []error
 }
 
 var blue7 = []string{
@@ -114,6 +175,20 @@ var blue7 = []string{
 	"rgb(239,243,255)",
 }
 
+// seriespalette is the default set of colors for multi-series charts
+var seriespalette = []string{
+	"rgb(31,119,180)",
+	"rgb(255,127,14)",
+	"rgb(44,160,44)",
+	"rgb(214,39,40)",
+	"rgb(148,103,189)",
+	"rgb(140,86,75)",
+	"rgb(227,119,194)",
+	"rgb(127,127,127)",
+	"rgb(188,189,34)",
+	"rgb(23,190,207)",
+}
+
 var xmlmap = strings.NewReplacer(
 	"&", "&amp;",
 	"<", "&lt;",
@@ -137,6 +212,7 @@ const (
 	topclock     = math.Pi / 2
 	fullcircle   = math.Pi * 2
 	transparency = 50.0
+	candlevolume = 0.25 // fraction of the chart height for the candlestick volume panel
 )
 
 // xmlesc escapes XML
@@ -149,27 +225,206 @@ func vmap(value float64, low1 float64, high1 float64, low2 float64, high2 float6
 	return low2 + (high2-low2)*(value-low1)/(high1-low1)
 }
 
-// getheader returns the indicies of the comma-separated list of fields
-// by default or on error, return 0, 1
+// getheader returns the index of the label column and the indicies of the value columns
+// from the comma-separated list of fields, along with the fields not in the header.
+// by default or on error, return 0, [1]; missing fields keep their position in the list.
 // For example given this header:
 // First,Second,Third,Sum
-// First,Sum returns 0,3 and First,Third returns 0,2
-func getheader(s []string, lv string) (int, int) {
+// First,Sum returns 0,[3] and First,Second,Third returns 0,[1,2]
+func getheader(s []string, lv string) (int, []int, []string) {
 	li := 0
-	vi := 1
+	vi := []int{1}
 	cv := strings.Split(lv, ",")
-	if len(cv) != 2 {
-		return li, vi
+	if len(cv) < 2 {
+		return li, vi, nil
+	}
+	vi = make([]int, len(cv)-1)
+	for k := range vi {
+		vi[k] = k + 1
 	}
+	found := make([]bool, len(cv))
 	for i, p := range s {
-		if p == cv[0] {
-			li = i
+		for k, c := range cv {
+			if p != c {
+				continue
+			}
+			found[k] = true
+			if k == 0 {
+				li = i
+			} else {
+				vi[k-1] = i
+			}
+		}
+	}
+	var missing []string
+	for k, c := range cv {
+		if !found[k] {
+			missing = append(missing, c)
+		}
+	}
+	return li, vi, missing
+}
+
+// Dataset is the data read from an input source, along with its extrema and title.
+// Warnings holds the problems found in the input when reading in lenient mode.
+type Dataset struct {
+	Data     []ChartData
+	Min, Max float64
+	Title    string
+	Warnings []error
+}
+
+// ParseError describes a problem with the input data:
+// the line, column name and offending text
+type ParseError struct {
+	Line   int
+	Column string
+	Text   string
+	Err    error
+}
+
+// Error returns the description of a parse error
+func (e *ParseError) Error() string {
+	switch {
+	case len(e.Column) == 0:
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	case len(e.Text) == 0:
+		return fmt.Sprintf("line %d, column %s: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %s: %q: %v", e.Line, e.Column, e.Text, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// errNumber is the error for values that are not finite numbers
+var errNumber = errors.New("not a finite number")
+
+// errColumn is the error for CSV columns that are not in the header
+var errColumn = errors.New("not in the header")
+
+// errMissing is the error for values missing from a row
+var errMissing = errors.New("missing")
+
+// parsenumber parses a data value, which must be finite:
+// NaN and infinities are errNumber. Values in error are zero.
+func parsenumber(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errNumber
+	}
+	return v, nil
+}
+
+// parsevalues parses the value columns of a row. The first column is always
+// a value (zero if not a number), subsequent numeric columns are additional series.
+// A trailing non-numeric column is the annotation.
+// The indicies of the columns that are not finite numbers are also returned.
+func parsevalues(fields []string) ([]float64, string, []int) {
+	var bad []int
+	v, err := parsenumber(fields[0])
+	if err != nil {
+		bad = append(bad, 0)
+	}
+	values := []float64{v}
+	for i := 1; i < len(fields); i++ {
+		v, err := parsenumber(fields[i])
+		if err != nil {
+			if i == len(fields)-1 && err != errNumber {
+				return values, xmlesc(fields[i]), bad
+			}
+			bad = append(bad, i)
+		}
+		values = append(values, v)
+	}
+	return values, "", bad
+}
+
+// minmax returns the minimum and maximum of a set of values,
+// updating the current extrema
+func minmax(values []float64, min, max float64) (float64, float64) {
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+		if v < min {
+			min = v
+		}
+	}
+	return min, max
+}
+
+// nseries returns the number of series (value columns) in the data
+func nseries(data []ChartData) int {
+	n := 1
+	for _, d := range data {
+		if len(d.Values) > n {
+			n = len(d.Values)
 		}
-		if p == cv[1] {
-			vi = i
+	}
+	return n
+}
+
+// NewDataset makes a dataset from data in memory, computing the extrema.
+// Labels, annotations and the title are escaped for markup, and each item's
+// Value and Values are made consistent.
+func NewDataset(data []ChartData, title string) Dataset {
+	ds := Dataset{Data: make([]ChartData, len(data)), Min: largest, Max: smallest, Title: xmlesc(title)}
+	for i, d := range data {
+		d.Label = xmlesc(d.Label)
+		d.Note = xmlesc(d.Note)
+		if len(d.Values) == 0 {
+			d.Values = []float64{d.Value}
+		} else {
+			d.Value = d.Values[0]
 		}
+		ds.Min, ds.Max = minmax(d.Values, ds.Min, ds.Max)
+		ds.Data[i] = d
+	}
+	return ds
+}
+
+// ReadData reads tab-separated or CSV data from a Reader.
+// In strict mode, reading stops at the first problem, which is returned as the error;
+// otherwise unparsable values are read as zero, and the problems are collected as warnings.
+func ReadData(r io.Reader, readcsv bool, cols string, strict bool) (Dataset, error) {
+	if readcsv {
+		return readCSV(r, cols, strict)
+	}
+	return readTSV(r, strict, false)
+}
+
+// triples reports whether the chart data are triples, keyed by label and annotation:
+// heatmaps have row, column, value triples, and grouped treemaps group, label, value triples
+func (s *Settings) triples() bool {
+	return s.Flags.ShowHeatmap || (s.Flags.ShowTreemap && s.Flags.Grouped)
+}
+
+// dataset reads and transforms the input data according to the settings,
+// closing the reader and collecting warnings. Heatmaps read row, column, value triples,
+// and grouped treemaps read group, label, value triples.
+func (s *Settings) dataset(r io.ReadCloser) (Dataset, error) {
+	var ds Dataset
+	var err error
+	switch {
+	case s.triples():
+		ds, err = readtriples(r, s.Flags.ReadCSV, s.Flags.StrictData)
+	case !s.Flags.ReadCSV:
+		ds, err = readTSV(r, s.Flags.StrictData, s.Flags.TSVNote)
+	default:
+		ds, err = ReadData(r, s.Flags.ReadCSV, s.Attributes.CSVCols, s.Flags.StrictData)
 	}
-	return li, vi
+	r.Close()
+	s.Warnings = append(s.Warnings, ds.Warnings...)
+	if err != nil {
+		return ds, err
+	}
+	return s.transform(ds)
 }
 
 // Getdata reads input from a Reader, either tab-separated or CSV
@@ -186,20 +441,38 @@ func Getdata(r io.ReadCloser, readcsv bool, cols string) ([]ChartData, float64,
 }
 
 // CSVdata reads CSV structured name,value pairs, with optional comments,
-// returning a slice with the data, allong with min, max and title
+// returning a slice with the data, allong with min, max and title.
+// Multiple value columns named in csvcols are read as multiple series
 func CSVdata(r io.ReadCloser, csvcols string) ([]ChartData, float64, float64, string) {
+	ds, _ := readCSV(r, csvcols, false)
+	r.Close()
+	return ds.Data, ds.Min, ds.Max, ds.Title
+}
+
+// TSVdata reads tab-delimited name,value pairs, with optional comments,
+// returning a slice with the data, allong with min, max and title.
+// Multiple value columns are read as multiple series
+func TSVdata(r io.ReadCloser) ([]ChartData, float64, float64, string) {
+	ds, _ := readTSV(r, false, false)
+	r.Close()
+	return ds.Data, ds.Min, ds.Max, ds.Title
+}
+
+// readCSV reads CSV data, reporting problems as errors (strict) or warnings
+func readCSV(r io.Reader, csvcols string, strict bool) (Dataset, error) {
 	var (
-		data []ChartData
-		d    ChartData
-		err  error
+		ds     Dataset
+		d      ChartData
+		header []string
 	)
 	input := csv.NewReader(r)
+	input.FieldsPerRecord = -1
 	maxval := smallest
 	minval := largest
 	title := ""
 	n := 0
 	li := 0
-	vi := 1
+	vi := []int{1}
 	for {
 		n++
 		fields, csverr := input.Read()
@@ -207,7 +480,15 @@ func CSVdata(r io.ReadCloser, csvcols string) ([]ChartData, float64, float64, st
 			break
 		}
 		if csverr != nil {
-			fmt.Fprintf(os.Stderr, "%v %v\n", csverr, fields)
+			var line int
+			if pe, ok := csverr.(*csv.ParseError); ok {
+				line = pe.Line
+			}
+			perr := &ParseError{Line: line, Err: csverr}
+			if strict {
+				return ds, perr
+			}
+			ds.Warnings = append(ds.Warnings, perr)
 			continue
 		}
 
@@ -218,50 +499,81 @@ func CSVdata(r io.ReadCloser, csvcols string) ([]ChartData, float64, float64, st
 			title = fields[1]
 			continue
 		}
-		if len(fields) == 3 {
-			d.note = xmlesc(fields[2])
-		} else {
-			d.note = ""
-		}
 		if n == 1 && len(csvcols) > 0 { // column header is assumed to be the first row
-			li, vi = getheader(fields, csvcols)
-			title = fields[vi]
+			var missing []string
+			li, vi, missing = getheader(fields, csvcols)
+			for _, c := range missing {
+				line, _ := input.FieldPos(0)
+				perr := &ParseError{Line: line, Column: c, Err: errColumn}
+				if strict {
+					return ds, perr
+				}
+				ds.Warnings = append(ds.Warnings, perr)
+			}
+			if vi[0] < len(fields) {
+				title = fields[vi[0]]
+			}
+			header = fields
 			continue
 		}
 
-		d.label = xmlesc(fields[li])
-		d.value, err = strconv.ParseFloat(fields[vi], 64)
-		if err != nil {
-			d.value = 0
-		}
-		if d.value > maxval {
-			maxval = d.value
+		d.Label = xmlesc(fields[li])
+		// the value columns: the second, or those named with csvcols
+		d.Values = make([]float64, len(vi))
+		for k, i := range vi {
+			var text string
+			if i < len(fields) {
+				text = fields[i]
+			}
+			v, err := parsenumber(text)
+			d.Values[k] = v
+			if err == nil {
+				continue
+			}
+			line, _ := input.FieldPos(0)
+			col := strconv.Itoa(i + 1)
+			if i < len(header) {
+				col = header[i]
+			}
+			perr := &ParseError{Line: line, Column: col, Text: text, Err: errNumber}
+			if i >= len(fields) {
+				perr.Err = errMissing
+			}
+			if strict {
+				return ds, perr
+			}
+			ds.Warnings = append(ds.Warnings, perr)
 		}
-		if d.value < minval {
-			minval = d.value
+		if len(vi) == 1 && len(fields) == 3 {
+			d.Note = xmlesc(fields[2])
+		} else {
+			d.Note = ""
 		}
-		data = append(data, d)
+		d.Value = d.Values[0]
+		minval, maxval = minmax(d.Values, minval, maxval)
+		ds.Data = append(ds.Data, d)
 	}
-	r.Close()
-	return data, minval, maxval, xmlesc(title)
+	ds.Min, ds.Max, ds.Title = minval, maxval, xmlesc(title)
+	return ds, nil
 }
 
-// TSVdata reads tab-delimited name,value pairs, with optional comments,
-// returning a slice with the data, allong with min, max and title
-func TSVdata(r io.ReadCloser) ([]ChartData, float64, float64, string) {
+// readTSV reads tab-separated data, reporting problems as errors (strict) or warnings.
+// If note3 is set, the third of three columns is the annotation, even if it is a number.
+func readTSV(r io.Reader, strict, note3 bool) (Dataset, error) {
 	var (
-		data []ChartData
-		d    ChartData
-		err  error
+		ds Dataset
+		d  ChartData
 	)
 
 	maxval := smallest
 	minval := largest
 	title := ""
+	line := 0
 	scanner := bufio.NewScanner(r)
 	// read a line, parse into name, value pairs
 	// compute min and max values
 	for scanner.Scan() {
+		line++
 		t := scanner.Text()
 		if len(t) == 0 { // skip blank lines
 			continue
@@ -271,34 +583,39 @@ func TSVdata(r io.ReadCloser) ([]ChartData, float64, float64, string) {
 			continue
 		}
 		fields := strings.Split(t, "\t")
-		if len(fields) < 2 {
-			continue
+		// a line with a single number is an unlabeled value
+		if len(fields) == 1 {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(t), 64); err != nil {
+				continue
+			}
+			fields = []string{"", strings.TrimSpace(t)}
 		}
-		if len(fields) == 3 {
-			d.note = xmlesc(fields[2])
+		var bad []int
+		d.Label = xmlesc(fields[0])
+		if note3 && len(fields) == 3 {
+			d.Values, _, bad = parsevalues(fields[1:2])
+			d.Note = xmlesc(fields[2])
 		} else {
-			d.note = ""
+			d.Values, d.Note, bad = parsevalues(fields[1:])
 		}
-		d.label = xmlesc(fields[0])
-		d.value, err = strconv.ParseFloat(fields[1], 64)
-		if err != nil {
-			d.value = 0
-		}
-		if d.value > maxval {
-			maxval = d.value
-		}
-		if d.value < minval {
-			minval = d.value
+		for _, b := range bad {
+			perr := &ParseError{Line: line, Column: strconv.Itoa(b + 2), Text: fields[b+1], Err: errNumber}
+			if strict {
+				return ds, perr
+			}
+			ds.Warnings = append(ds.Warnings, perr)
 		}
-		data = append(data, d)
+		d.Value = d.Values[0]
+		minval, maxval = minmax(d.Values, minval, maxval)
+		ds.Data = append(ds.Data, d)
 	}
-	r.Close()
-	return data, minval, maxval, xmlesc(title)
+	ds.Min, ds.Max, ds.Title = minval, maxval, xmlesc(title)
+	return ds, scanner.Err()
 }
 
 // dottedvline makes dotted vertical line, using circles,
 // with specified step
-func dottedvline(d *deckgen.DeckGen, x, y1, y2, dotsize, step float64, color string) {
+func dottedvline(d Renderer, x, y1, y2, dotsize, step float64, color string) {
 
 	if y1 < y2 { // positive
 		for y := y1; y <= y2; y += step {
@@ -313,7 +630,7 @@ func dottedvline(d *deckgen.DeckGen, x, y1, y2, dotsize, step float64, color str
 
 // dottedhline makes a dotted horizontal line, using circles,
 // with specified step and separation
-func dottedhline(d *deckgen.DeckGen, x, y, width, height, step, space float64, color string) {
+func dottedhline(d Renderer, x, y, width, height, step, space float64, color string) {
 	for xp := x; xp < x+width; xp += step {
 		d.Circle(xp, y, height, color)
 		xp += space
@@ -330,38 +647,437 @@ func yrange(s string) (float64, float64, float64) {
 	return min, max, step
 }
 
-// cyrange computes "optimal" min, max, step for axis labels
-// rounding the max to the appropriate number, given the number of labels
+// nicenum returns a "nice" number approximately equal to x:
+// 1, 2, 5 or 10 times a power of ten. The number is rounded if round is true,
+// otherwise it is the ceiling.
+func nicenum(x float64, round bool) float64 {
+	exp := math.Floor(math.Log10(x))
+	f := x / math.Pow(10, exp)
+	var nf float64
+	if round {
+		switch {
+		case f < 1.5:
+			nf = 1
+		case f < 3:
+			nf = 2
+		case f < 7:
+			nf = 5
+		default:
+			nf = 10
+		}
+	} else {
+		switch {
+		case f <= 1:
+			nf = 1
+		case f <= 2:
+			nf = 2
+		case f <= 5:
+			nf = 5
+		default:
+			nf = 10
+		}
+	}
+	return nf * math.Pow(10, exp)
+}
+
+// cyrange computes "optimal" min, max, step for axis labels with about n labels,
+// using Heckbert's nice numbers. Negative, fractional and empty ranges are handled;
+// reversed or infinite ranges (from no data) are the unit range.
 func cyrange(min, max float64, n int) (float64, float64, float64) {
-	l := math.Log10(max)
-	p := math.Pow10(int(l))
-	pl := math.Ceil(max / p)
-	ymax := pl * p
-	return min, ymax, ymax / float64(n)
+	if min > max || math.IsInf(max-min, 0) || math.IsNaN(max-min) {
+		min, max = 0, 1
+	}
+	min, max = expandrange(min, max)
+	if n < 2 {
+		n = 2
+	}
+	r := nicenum(max-min, false)
+	step := nicenum(r/float64(n-1), true)
+	return math.Floor(min/step) * step, math.Ceil(max/step) * step, step
+}
+
+// expandrange widens an empty data range (for example, all zeros)
+// so that values can be mapped
+func expandrange(min, max float64) (float64, float64) {
+	if min != max {
+		return min, max
+	}
+	if min == 0 {
+		return 0, 1
+	}
+	d := math.Abs(min) * 0.5
+	return min - d, max + d
+}
+
+// nticks returns the number of axis labels that fit in a length
+func (s *Settings) nticks(length float64) int {
+	n := int(length / (s.Measures.TextSize * 5))
+	if n < 2 {
+		n = 2
+	}
+	return n
+}
+
+// axislabel formats an axis value: using the data format if specified,
+// otherwise with the number of decimals needed for the step
+func (s *Settings) axislabel(v, step float64) string {
+	if math.Abs(v) < step*1e-9 { // avoid -0
+		v = 0
+	}
+	if df := s.Attributes.DataFmt; len(df) > 0 && df != Defaultfmt {
+		return dformat(df, v)
+	}
+	prec := 0
+	if step > 0 && step < 1 {
+		prec = int(math.Ceil(-math.Log10(step) - 1e-9))
+	}
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+// axisvalues returns the labeled values of an axis, limited to the data range;
+// there are none unless the axis and step are finite
+func axisvalues(axismin, axismax, step, dmin, dmax float64) []float64 {
+	if !(step > 0) || math.IsInf(step, 0) || math.IsInf(axismax-axismin, 0) || math.IsNaN(axismax-axismin) {
+		return nil
+	}
+	var values []float64
+	eps := step * 1e-9
+	lo, hi := math.Min(dmin, dmax), math.Max(dmin, dmax)
+	for i := 0; ; i++ {
+		v := axismin + float64(i)*step
+		if v > axismax+eps {
+			break
+		}
+		if v >= lo-eps && v <= hi+eps {
+			values = append(values, v)
+		}
+	}
+	return values
 }
 
 // yaxis constructs y axis labels
-func (s *Settings) yaxis(deck *deckgen.DeckGen, x, dmin, dmax float64) {
+func (s *Settings) yaxis(deck Renderer, x, dmin, dmax float64) {
+	if s.Flags.LogScale && s.Attributes.YAxisR == "" {
+		s.logaxis(deck, x, dmin, dmax)
+		return
+	}
 	var axismin, axismax, step float64
 	if s.Attributes.YAxisR == "" {
-		axismin, axismax, step = cyrange(dmin, dmax, 5)
+		axismin, axismax, step = cyrange(dmin, dmax, s.nticks(s.Measures.Top-s.Measures.Bottom))
 	} else {
 		axismin, axismax, step = yrange(s.Attributes.YAxisR)
 	}
 	if step <= 0 {
 		return
 	}
-	var axisfmt = "%0.f"
-	if step < 1 {
-		axisfmt = "%3.2f"
+	left := s.Measures.Left
+	if left < 0 {
+		left = 10.0
+	}
+	for _, y := range axisvalues(axismin, axismax, step, dmin, dmax) {
+		yp := s.scale(y, dmin, dmax, s.Measures.Bottom, s.Measures.Top)
+		deck.TextEnd(x, yp, s.axislabel(y, step), "sans", s.Measures.TextSize*0.75, s.Attributes.LabelColor)
+		if s.Flags.ShowGrid {
+			deck.Line(left, yp, s.Measures.Right, yp, 0.1, "lightgray")
+		}
+	}
+}
+
+// Defaulttimefmt is the default layout for parsing time labels
+const Defaulttimefmt = "2006-01-02"
+
+// timestep is a calendar interval for time axis ticks
+type timestep struct {
+	years, months, days int
+	d                   time.Duration
+	layout              string // label layout
+	ctx                 string // layout for context (shown when it changes)
+}
+
+// timesteps are the candidate tick intervals, from smallest to largest
+var timesteps = []timestep{
+	{d: time.Hour, layout: "15:04", ctx: "Jan 2"},
+	{d: 6 * time.Hour, layout: "15:04", ctx: "Jan 2"},
+	{d: 12 * time.Hour, layout: "15:04", ctx: "Jan 2"},
+	{days: 1, layout: "Jan 2", ctx: "2006"},
+	{days: 2, layout: "Jan 2", ctx: "2006"},
+	{days: 7, layout: "Jan 2", ctx: "2006"},
+	{days: 14, layout: "Jan 2", ctx: "2006"},
+	{months: 1, layout: "Jan", ctx: "2006"},
+	{months: 3, layout: "Q", ctx: "2006"},
+	{months: 6, layout: "Jan", ctx: "2006"},
+	{years: 1, layout: "2006"},
+	{years: 2, layout: "2006"},
+	{years: 5, layout: "2006"},
+	{years: 10, layout: "2006"},
+	{years: 25, layout: "2006"},
+	{years: 50, layout: "2006"},
+	{years: 100, layout: "2006"},
+}
+
+// span returns the approximate length of a time step
+func (ts timestep) span() time.Duration {
+	day := 24 * time.Hour
+	return ts.d + time.Duration(ts.days)*day + time.Duration(ts.months)*30*day + time.Duration(ts.years)*365*day
+}
+
+// add advances a time by the step
+func (ts timestep) add(t time.Time) time.Time {
+	if ts.d > 0 {
+		return t.Add(ts.d)
+	}
+	return t.AddDate(ts.years, ts.months, ts.days)
+}
+
+// floor truncates a time to the beginning of the step's calendar unit
+func (ts timestep) floor(t time.Time) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch {
+	case ts.d > 0:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case ts.days == 7 || ts.days == 14: // weeks begin on Monday
+		wd := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-wd, 0, 0, 0, 0, loc)
+	case ts.days > 0:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case ts.months > 0:
+		return time.Date(y, m-time.Month((int(m)-1)%ts.months), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y-(y%ts.years), 1, 1, 0, 0, 0, 0, loc)
+	}
+}
+
+// format makes a tick label, adding the context (the year or day)
+// on a second line if it differs from the previous tick
+func (ts timestep) format(t time.Time, prev string) (string, string) {
+	label := t.Format(ts.layout)
+	if ts.layout == "Q" {
+		label = fmt.Sprintf("Q%d", (int(t.Month())-1)/3+1)
+	}
+	if len(ts.ctx) == 0 {
+		return label, prev
+	}
+	ctx := t.Format(ts.ctx)
+	if ctx != prev {
+		label += `\n` + ctx
+	}
+	return label, ctx
+}
+
+// timeticks chooses calendar-aware ticks for a time range, with at most n ticks
+func timeticks(tmin, tmax time.Time, n int) ([]time.Time, timestep) {
+	span := tmax.Sub(tmin)
+	step := timesteps[len(timesteps)-1]
+	for _, ts := range timesteps {
+		if int(span/ts.span()) < n {
+			step = ts
+			break
+		}
+	}
+	var ticks []time.Time
+	for t := step.floor(tmin); !t.After(tmax); t = step.add(t) {
+		if !t.Before(tmin) {
+			ticks = append(ticks, t)
+		}
+	}
+	return ticks, step
+}
+
+// parsetimes parses the data labels as times
+func (s *Settings) parsetimes(data []ChartData) ([]time.Time, error) {
+	layout := s.Attributes.TimeFormat
+	if len(layout) == 0 {
+		layout = Defaulttimefmt
+	}
+	times := make([]time.Time, len(data))
+	for i, d := range data {
+		t, err := time.Parse(layout, d.Label)
+		if err != nil {
+			return nil, fmt.Errorf("label %q is not a time in the layout %q", d.Label, layout)
+		}
+		times[i] = t
+	}
+	return times, nil
+}
+
+// xpositions returns the x coordinate of each data item, evenly spaced by index,
+// or in proportion to time if the labels are times, along with the smallest spacing
+func (s *Settings) xpositions(data []ChartData, times []time.Time, left, right float64) ([]float64, float64) {
+	l := len(data)
+	xpos := make([]float64, l)
+	if times == nil {
+		if l == 1 {
+			xpos[0] = left + (right-left)/2
+			return xpos, (right - left) / 2
+		}
+		dlen := float64(l - 1)
+		for i := range data {
+			xpos[i] = vmap(float64(i), 0, dlen, left, right)
+		}
+		return xpos, (right - left) / dlen
+	}
+	tmin, tmax := timespan(times)
+	for i, t := range times {
+		xpos[i] = vmap(float64(t.Unix()), float64(tmin.Unix()), float64(tmax.Unix()), left, right)
+	}
+	gap := right - left
+	if l == 1 { // a day, of the two days of the span
+		gap /= 2
+	}
+	for i := 1; i < l; i++ {
+		if g := math.Abs(xpos[i] - xpos[i-1]); g > 0 && g < gap {
+			gap = g
+		}
+	}
+	return xpos, gap
+}
+
+// timerange returns the earliest and latest times (zero times if there are none)
+func timerange(times []time.Time) (time.Time, time.Time) {
+	if len(times) == 0 {
+		return time.Time{}, time.Time{}
+	}
+	tmin, tmax := times[0], times[0]
+	for _, t := range times {
+		if t.Before(tmin) {
+			tmin = t
+		}
+		if t.After(tmax) {
+			tmax = t
+		}
+	}
+	return tmin, tmax
+}
+
+// timespan returns the extent of a time axis: the earliest and latest times,
+// or a day either side of a single time (or of the zero time if there are none)
+func timespan(times []time.Time) (time.Time, time.Time) {
+	tmin, tmax := timerange(times)
+	if tmin.Equal(tmax) {
+		return tmin.AddDate(0, 0, -1), tmax.AddDate(0, 0, 1)
+	}
+	return tmin, tmax
+}
+
+// timeaxis makes the ticks and labels of a time axis
+func (s *Settings) timeaxis(deck Renderer, times []time.Time, left, right, bottom float64) {
+	if len(times) == 0 {
+		return
+	}
+	ts := s.Measures.TextSize
+	tmin, tmax := timespan(times)
+	ticks, step := timeticks(tmin, tmax, int((right-left)/(ts*6))+1)
+	prev := ""
+	var label string
+	for i, t := range ticks {
+		x := vmap(float64(t.Unix()), float64(tmin.Unix()), float64(tmax.Unix()), left, right)
+		label, prev = step.format(t, prev)
+		deck.Line(x, bottom, x, bottom-(ts/2), 0.1, s.Attributes.LabelColor)
+		if s.Flags.ShowGrid {
+			deck.Line(x, bottom, x, s.Measures.Top, 0.1, "lightgray")
+		}
+		s.xlabel(deck, x, bottom, label, i)
+	}
+}
+
+// xlabel places a (possibly multi-line) x axis label below the bottom at x,
+// staggered and rotated if specified
+func (s *Settings) xlabel(deck Renderer, x, bottom float64, label string, i int) {
+	ts := s.Measures.TextSize
+	labelcolor := s.Attributes.LabelColor
+	xlabels := strings.Split(label, `\n`)
+	xly := bottom - (ts * 2)
+	for _, xl := range xlabels {
+		if s.Flags.ShowXstagger && (i+1)%2 == 0 {
+			xly -= (ts * 2)
+		}
+		if s.Measures.XLabelRotation == 0 {
+			deck.TextMid(x, xly, xl, "sans", ts*0.8, labelcolor)
+		} else {
+			deck.TextRotate(x, xly, xl, "", "sans", s.Measures.XLabelRotation, ts*0.8, labelcolor)
+		}
+		xly -= ts * 1.2
+	}
+}
+
+// logb returns the logarithm of v in base b
+func logb(v, b float64) float64 {
+	return math.Log(v) / math.Log(b)
+}
+
+// logbase returns the base of logarithmic scales (default 10)
+func (s *Settings) logbase() float64 {
+	if s.Measures.LogBase <= 1 {
+		return 10
+	}
+	return s.Measures.LogBase
+}
+
+// scale maps a data value into a coordinate range, linearly or on a logarithmic scale.
+// On a logarithmic scale non-positive values are clipped to the minimum.
+func (s *Settings) scale(v, dmin, dmax, low, high float64) float64 {
+	if !s.Flags.LogScale {
+		return vmap(v, dmin, dmax, low, high)
+	}
+	b := s.logbase()
+	if v < dmin {
+		v = dmin
+	}
+	return vmap(logb(v, b), logb(dmin, b), logb(dmax, b), low, high)
+}
+
+// logrange returns the extrema of a logarithmic scale, rounded to powers of the base.
+// Non-positive values cannot be shown: they are an error in strict mode,
+// otherwise they are reported as warnings, and clipped to the minimum.
+func (s *Settings) logrange(data []ChartData, dmin, dmax float64) (float64, float64, error) {
+	b := s.logbase()
+	minpos := largest
+	for _, d := range data {
+		for _, v := range d.Values {
+			if v > 0 && v < minpos {
+				minpos = v
+			}
+			if v <= 0 {
+				err := fmt.Errorf("log scale: value %v of %q is not positive", v, d.Label)
+				if s.Flags.StrictData {
+					return dmin, dmax, err
+				}
+				s.Warnings = append(s.Warnings, fmt.Errorf("%v, clipped to the minimum", err))
+			}
+		}
+	}
+	if minpos == largest {
+		return dmin, dmax, errors.New("log scale: no positive values")
+	}
+	if dmin < minpos {
+		dmin = minpos
+	}
+	dmin = math.Pow(b, math.Floor(logb(dmin, b)))
+	dmax = math.Pow(b, math.Ceil(logb(dmax, b)))
+	if dmax <= dmin {
+		dmax = dmin * b
 	}
+	if s.Measures.UserMin > 0 {
+		dmin = s.Measures.UserMin
+	}
+	if s.Measures.UserMax > 0 && s.Measures.UserMax > dmin {
+		dmax = s.Measures.UserMax
+	}
+	return dmin, dmax, nil
+}
+
+// logaxis constructs y axis labels at the powers of the base
+func (s *Settings) logaxis(deck Renderer, x, dmin, dmax float64) {
+	b := s.logbase()
 	left := s.Measures.Left
 	if left < 0 {
 		left = 10.0
 	}
-	for y := axismin; y <= axismax; y += step {
-		yp := vmap(y, dmin, dmax, s.Measures.Bottom, s.Measures.Top)
-		deck.TextEnd(x, yp, fmt.Sprintf(axisfmt, y), "sans", s.Measures.TextSize*0.75, s.Attributes.LabelColor)
+	for e := math.Ceil(logb(dmin, b) - 1e-9); e <= math.Floor(logb(dmax, b)+1e-9); e++ {
+		y := math.Pow(b, e)
+		yp := s.scale(y, dmin, dmax, s.Measures.Bottom, s.Measures.Top)
+		deck.TextEnd(x, yp, strconv.FormatFloat(y, 'g', 6, 64), "sans", s.Measures.TextSize*0.75, s.Attributes.LabelColor)
 		if s.Flags.ShowGrid {
 			deck.Line(left, yp, s.Measures.Right, yp, 0.1, "lightgray")
 		}
@@ -424,11 +1140,29 @@ func dformat(datafmt string, x float64) string {
 	return fmt.Sprintf(datafmt, x)
 }
 
+// tooltip describes a data element to renderers that show tooltips:
+// the label, formatted value, percentage of sum (if not zero) and note
+func (s *Settings) tooltip(deck Renderer, label string, value, sum float64, note string) {
+	t, ok := deck.(Tooltipper)
+	if !ok {
+		return
+	}
+	df := s.Attributes.DataFmt
+	tip := label + ": " + dformat(df, value)
+	if sum != 0 {
+		tip += " (" + dformat(df, 100*(value/sum)) + "%)"
+	}
+	if len(note) > 0 {
+		tip += "\n" + note
+	}
+	t.Tooltip(tip)
+}
+
 // datasum computes the sum of the chart data
 func datasum(data []ChartData) float64 {
 	sum := 0.0
 	for _, d := range data {
-		sum += d.value
+		sum += d.Value
 	}
 	return sum
 }
@@ -437,16 +1171,60 @@ func datasum(data []ChartData) float64 {
 func pct(data []ChartData) []float64 {
 	sum := 0.0
 	for _, d := range data {
-		sum += d.value
+		sum += d.Value
 	}
 
 	p := make([]float64, len(data))
 	for i, d := range data {
-		p[i] = (d.value / sum) * 100
+		p[i] = (d.Value / sum) * 100
 	}
 	return p
 }
 
+// rowdata makes chart data from the series values of a row
+func rowdata(d ChartData) []ChartData {
+	data := make([]ChartData, len(d.Values))
+	for i, v := range d.Values {
+		data[i] = ChartData{Label: d.Label, Value: v, Values: []float64{v}}
+	}
+	return data
+}
+
+// stackrow returns the low and high extents of each series in a row,
+// stacking positive values up from zero, and negative values down from zero.
+// If normalized, the values are percentages of the row sum.
+func stackrow(d ChartData, n int, normalized bool) ([]float64, []float64) {
+	values := make([]float64, n)
+	copy(values, d.Values)
+	if normalized {
+		copy(values, pct(rowdata(d)))
+	}
+	lo := make([]float64, n)
+	hi := make([]float64, n)
+	var pos, neg float64
+	for k, v := range values {
+		if v < 0 {
+			lo[k], hi[k] = neg+v, neg
+			neg += v
+		} else {
+			lo[k], hi[k] = pos, pos+v
+			pos += v
+		}
+	}
+	return lo, hi
+}
+
+// stackrange returns the minimum and maximum of the stacked data
+func stackrange(data []ChartData, n int, normalized bool) (float64, float64) {
+	min, max := 0.0, 0.0
+	for _, d := range data {
+		lo, hi := stackrow(d, n, normalized)
+		min, max = minmax(lo, min, max)
+		min, max = minmax(hi, min, max)
+	}
+	return min, max
+}
+
 // parsecondition parses the expression low,high,color. For example "0,10,red"
 // means color the data red if the value is between 0 and 10.
 func parsecondition(s string) (float64, float64, string, error) {
@@ -496,7 +1274,7 @@ func Parsebounds(s string) (float64, float64, float64, float64) {
 }
 
 // pgrid makes a proportional grid with the specified rows and columns
-func (s *Settings) pgrid(deck *deckgen.DeckGen, data []ChartData, title string, rows, cols int) {
+func (s *Settings) pgrid(deck Renderer, data []ChartData, title string, rows, cols int) {
 
 	ls := s.Measures.LineSpacing
 	ts := s.Measures.TextSize
@@ -515,19 +1293,21 @@ func (s *Settings) pgrid(deck *deckgen.DeckGen, data []ChartData, title string,
 
 	sum := 0.0
 	for _, d := range data {
-		sum += d.value
+		sum += d.Value
 	}
 	pct := make([]float64, len(data))
 	for i, d := range data {
-		pct[i] = math.Floor((d.value / sum) * 100)
+		pct[i] = math.Floor((d.Value / sum) * 100)
 	}
 
 	// encode the data in a string vector
 	chars := make([]string, 100)
+	items := make([]int, 100)
 	cb := 0
 	for k := 0; k < len(data); k++ {
 		for l := 0; l < int(pct[k]); l++ {
-			chars[cb] = data[k].note
+			chars[cb] = data[k].Note
+			items[cb] = k
 			cb++
 		}
 	}
@@ -541,6 +1321,10 @@ func (s *Settings) pgrid(deck *deckgen.DeckGen, data []ChartData, title string,
 			if n >= 100 {
 				break
 			}
+			if n < cb {
+				d := data[items[n]]
+				s.tooltip(deck, d.Label, d.Value, sum, d.Note)
+			}
 			deck.Circle(x, y, ts, chars[n])
 			n++
 			x += ls
@@ -556,16 +1340,16 @@ func (s *Settings) pgrid(deck *deckgen.DeckGen, data []ChartData, title string,
 	df := s.Attributes.DataFmt
 	for i, d := range data {
 		y -= ls * 1.2
-		deck.Circle(left, y, ts, d.note)
-		deck.Text(left+ts, y-(ts/2), d.label+" ("+dformat(df, pct[i])+"%)", "sans", ts, "")
+		deck.Circle(left, y, ts, d.Note)
+		deck.Text(left+ts, y-(ts/2), d.Label+" ("+dformat(df, pct[i])+"%)", "sans", ts, "")
 		if s.Flags.ShowValues {
-			deck.TextEnd(left+cx, y-(ts/2), dformat(df, d.value), "sans", ts, valuecolor)
+			deck.TextEnd(left+cx, y-(ts/2), dformat(df, d.Value), "sans", ts, valuecolor)
 		}
 	}
 }
 
 // dotgrid makes a grid 10x10 grid of dots colored by value
-func dotgrid(deck *deckgen.DeckGen, x, y, left, step float64, n int, fillcolor string) (float64, float64) {
+func dotgrid(deck Renderer, x, y, left, step float64, n int, fillcolor string) (float64, float64) {
 	edge := (((step * 0.3) + step) * 7) + left
 	for i := 0; i < n; i++ {
 		if x > edge {
@@ -580,7 +1364,7 @@ func dotgrid(deck *deckgen.DeckGen, x, y, left, step float64, n int, fillcolor s
 }
 
 // lego makes lego charts (a variation of pgrid)
-func (s *Settings) lego(deck *deckgen.DeckGen, data []ChartData, title string) {
+func (s *Settings) lego(deck Renderer, data []ChartData, title string) {
 	left := s.Measures.Left
 	x := left
 	y := s.Measures.Top
@@ -591,21 +1375,21 @@ func (s *Settings) lego(deck *deckgen.DeckGen, data []ChartData, title string) {
 	}
 	sum := 0.0
 	for _, d := range data {
-		sum += d.value
+		sum += d.Value
 	}
 	for _, d := range data {
-		pct := (d.value / sum) * 100
+		pct := (d.Value / sum) * 100
 		v := int(math.Round(pct))
-		px, py := dotgrid(deck, x, y, left, step, v, d.note)
+		px, py := dotgrid(deck, x, y, left, step, v, d.Note)
 		x = px
 		y = py
 	}
 	y -= step * 2
 	for _, d := range data {
-		pct := (d.value / sum) * 100
+		pct := (d.Value / sum) * 100
 		v := int(math.Round(pct))
-		deck.Circle(left, y, 2*step*0.3, d.note)
-		deck.Text(left+step, y-step*0.2, fmt.Sprintf("%s (%.d%%)", d.label, v), "sans", step*0.5, "")
+		deck.Circle(left, y, 2*step*0.3, d.Note)
+		deck.Text(left+step, y-step*0.2, fmt.Sprintf("%s (%.d%%)", d.Label, v), "sans", step*0.5, "")
 		y -= step
 	}
 }
@@ -626,7 +1410,7 @@ func cpolar(x, y, r, t, w, h float64) (float64, float64) {
 }
 
 // spokes draws the points and lines like spokes on a wheel
-func spokes(deck *deckgen.DeckGen, cx, cy, r, spokesize, w, h float64, n int, color string) {
+func spokes(deck Renderer, cx, cy, r, spokesize, w, h float64, n int, color string) {
 	t := topclock
 	step := fullcircle / float64(n)
 	for i := 0; i < n; i++ {
@@ -638,7 +1422,7 @@ func spokes(deck *deckgen.DeckGen, cx, cy, r, spokesize, w, h float64, n int, co
 }
 
 // radial draws a radial plot
-func (s *Settings) radial(deck *deckgen.DeckGen, data []ChartData, title string, maxd float64) {
+func (s *Settings) radial(deck Renderer, data []ChartData, title string, maxd float64) {
 	top := s.Measures.Top
 	left := s.Measures.Left
 	pwidth := s.Measures.PWidth
@@ -671,36 +1455,56 @@ func (s *Settings) radial(deck *deckgen.DeckGen, data []ChartData, title string,
 	var color string
 
 	for _, d := range data {
-		cv := vmap(d.value, 0, maxd, 2, psize)
+		cv := vmap(d.Value, 0, maxd, 2, psize)
 		px, py := cpolar(dx, dy, pwidth, t, rw, rh)
 		tx, ty := cpolar(dx, dy, pwidth+(psize/2)+(ts*2), t, rw, rh)
 
-		if len(d.note) > 0 {
-			color = d.note
+		if len(d.Note) > 0 {
+			color = d.Note
 		} else {
 			color = datacolor
 		}
 
-		deck.TextMid(tx, ty, d.label, "sans", ts/2, "black")
+		deck.TextMid(tx, ty, d.Label, "sans", ts/2, "black")
 		if s.Flags.ShowValues {
-			deck.TextMid(px, py-ts/3, dformat(s.Attributes.DataFmt, d.value), "mono", ts, s.Attributes.ValueColor)
+			deck.TextMid(px, py-ts/3, dformat(s.Attributes.DataFmt, d.Value), "mono", ts, s.Attributes.ValueColor)
 		}
 		if s.Flags.ShowSpokes {
-			spokes(deck, px, py, psize/2, 0.05, rw, rh, int(d.value), color)
+			spokes(deck, px, py, psize/2, 0.05, rw, rh, int(d.Value), color)
 		} else {
+			s.tooltip(deck, d.Label, d.Value, 0, d.Note)
 			deck.Circle(px, py, cv, color, transparency)
 			deck.Line(tx, ty, px, py, 0.05, "gray", 50)
 		}
 		t -= step
 	}
+	if s.Flags.ShowLegend {
+		items := datalegenditems(data, func(i int) (string, float64) {
+			if len(data[i].Note) > 0 {
+				return data[i].Note, transparency
+			}
+			return datacolor, transparency
+		})
+		r := pwidth + psize/2 + ts*2
+		ry := r * (rw / rh)
+		s.chartlegend(deck, items, dotswatch, dx-r, dx+r, dy+ry, dy-ry)
+	}
 }
 
 // Slopechart draws a slope chart
-func (s *Settings) Slopechart(deck *deckgen.DeckGen, r io.ReadCloser) {
-	data, mindata, maxdata, title := Getdata(r, s.Flags.ReadCSV, s.Attributes.CSVCols)
+func (s *Settings) Slopechart(deck Renderer, r io.ReadCloser) error {
+	ds, err := s.dataset(r)
+	if err != nil {
+		return err
+	}
+	return s.slopechart(deck, ds)
+}
+
+// slopechart draws a slope chart from a dataset
+func (s *Settings) slopechart(deck Renderer, ds Dataset) error {
+	data, mindata, maxdata, title := ds.Data, ds.Min, ds.Max, ds.Title
 	if len(data) < 2 {
-		fmt.Fprintf(os.Stderr, "slope graphs need at least two data points")
-		return
+		return errors.New("slope graphs need at least two data points")
 	}
 
 	datamin := s.Flags.DataMinimum
@@ -751,6 +1555,11 @@ func (s *Settings) Slopechart(deck *deckgen.DeckGen, r io.ReadCloser) {
 		deck.Text(left, top+10, title, "sans", hsize, Titlecolor)
 	}
 
+	refs, err := s.parsereferences()
+	if err != nil {
+		return err
+	}
+
 	// these are magical
 	hskip := w * .60
 	vskip := h * 1.4
@@ -759,20 +1568,31 @@ func (s *Settings) Slopechart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	x2 := right
 	// Process the data in pairs
 	for i := 0; i < len(data)-1; i += 2 {
-		if len(data[i].label) > 0 {
-			deck.TextMid(x1+(w/2), top+3, data[i].note, "sans", tsize, labelcolor)
+		if len(data[i].Label) > 0 {
+			deck.TextMid(x1+(w/2), top+3, data[i].Note, "sans", tsize, labelcolor)
 		}
-		v1 := data[i].value
-		v2 := data[i+1].value
+		v1 := data[i].Value
+		v2 := data[i+1].Value
 		v1y := vmap(v1, mindata, maxdata, bottom, top)
 		v2y := vmap(v2, mindata, maxdata, bottom, top)
+		// references are within each panel, labeled at the end of each row of panels
+		if len(refs) > 0 {
+			prefs := refs
+			if x2+w+hskip <= 100 && i+3 < len(data) {
+				prefs = unlabeled(refs)
+			}
+			value := func(v float64) float64 { return vmap(v, mindata, maxdata, bottom, top) }
+			s.drawreferences(deck, prefs, value, nil, false, x1, x2, bottom, top)
+		}
 		deck.Line(x1, bottom, x1, top, lw, "black")
 		deck.Line(x2, bottom, x2, top, lw, "black")
+		s.tooltip(deck, data[i].Label, v1, 0, data[i].Note)
 		deck.Circle(x1, v1y, ts, datacolor)
+		s.tooltip(deck, data[i+1].Label, v2, 0, data[i+1].Note)
 		deck.Circle(x2, v2y, ts, datacolor)
 		deck.Line(x1, v1y, x2, v2y, linewidth, datacolor)
-		deck.TextMid(x1, bottom-2, data[i].label, "sans", ts, labelcolor)
-		deck.TextMid(x2, bottom-2, data[i+1].label, "sans", ts, labelcolor)
+		deck.TextMid(x1, bottom-2, data[i].Label, "sans", ts, labelcolor)
+		deck.TextMid(x2, bottom-2, data[i+1].Label, "sans", ts, labelcolor)
 
 		// only Show max value id user-specified
 		df := s.Attributes.DataFmt
@@ -793,10 +1613,11 @@ func (s *Settings) Slopechart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	if s.Flags.FullDeck {
 		deck.EndSlide()
 	}
+	return nil
 }
 
 // pmap draws a porpotional map
-func (s *Settings) pmap(deck *deckgen.DeckGen, data []ChartData, title string) {
+func (s *Settings) pmap(deck Renderer, data []ChartData, title string) {
 	top := s.Measures.Top
 	left := s.Measures.Left
 	right := s.Measures.Right
@@ -814,18 +1635,20 @@ func (s *Settings) pmap(deck *deckgen.DeckGen, data []ChartData, title string) {
 	hspace := 0.10
 	var ty float64
 	var textcolor string
+	sum := datasum(data)
 	if len(title) > 0 && s.Flags.ShowTitle {
 		deck.TextMid(x+pl/2, top+(pwidth*2), title, "sans", ts*1.5, Titlecolor)
 	}
 	for i, p := range pct(data) {
 		bx := (p * bl)
-		if p < 3 || len(data[i].label) > pmlen {
+		if p < 3 || len(data[i].Label) > pmlen {
 			ty = top - pwidth*1.2
 			deck.Line(x+(bx/2), ty+(ts*1.5), x+(bx/2), top, 0.1, Dotlinecolor)
 		} else {
 			ty = top
 		}
-		linecolor, lineop := stdcolor(i, data[i].note, datacolor, p, s.Flags.SolidPMap)
+		linecolor, lineop := stdcolor(i, data[i].Note, datacolor, p, s.Flags.SolidPMap)
+		s.tooltip(deck, data[i].Label, data[i].Value, sum, data[i].Note)
 		deck.Line(x, top, bx+x, top, pwidth, linecolor, lineop)
 		if lineop == 100 {
 			textcolor = "white"
@@ -835,13 +1658,20 @@ func (s *Settings) pmap(deck *deckgen.DeckGen, data []ChartData, title string) {
 
 		df := s.Attributes.DataFmt
 		if s.Flags.ShowValues {
-			deck.TextMid(x+(bx/2), ty-pwidth, dformat(df, data[i].value), "mono", ts/2, s.Attributes.ValueColor)
+			deck.TextMid(x+(bx/2), ty-pwidth, dformat(df, data[i].Value), "mono", ts/2, s.Attributes.ValueColor)
 		}
-		deck.TextMid(x+(bx/2), ty+(pwidth), data[i].label, "sans", ts*0.75, s.LabelColor)
+		deck.TextMid(x+(bx/2), ty+(pwidth), data[i].Label, "sans", ts*0.75, s.LabelColor)
 		deck.TextMid(x+(bx/2), ty-(ts/2), fmt.Sprintf(df+"%%", p), "sans", ts, textcolor)
 
 		x += bx - hspace
 	}
+	if s.Flags.ShowLegend {
+		pcts := pct(data)
+		items := datalegenditems(data, func(i int) (string, float64) {
+			return stdcolor(i, data[i].Note, datacolor, pcts[i], s.Flags.SolidPMap)
+		})
+		s.chartlegend(deck, items, barswatch, left, right, top+pwidth*2, top-pwidth*2)
+	}
 }
 
 // stdcolor uses either the standard color (cycling through a list) or specified color and opacity
@@ -859,7 +1689,7 @@ func stdcolor(i int, dcolor, color string, op float64, solid bool) (string, floa
 }
 
 // donut makes a donut chart
-func (s *Settings) donut(deck *deckgen.DeckGen, data []ChartData, title string) {
+func (s *Settings) donut(deck Renderer, data []ChartData, title string) {
 	top := s.Measures.Top
 	left := s.Measures.Left
 	psize := s.Measures.PSize
@@ -870,6 +1700,7 @@ func (s *Settings) donut(deck *deckgen.DeckGen, data []ChartData, title string)
 		left = 50.0
 	}
 	a1 := 0.0
+	sum := datasum(data)
 	dx := left // + (psize / 2)
 	dy := top - (psize / 2)
 	if len(title) > 0 && s.Flags.ShowTitle {
@@ -880,14 +1711,23 @@ func (s *Settings) donut(deck *deckgen.DeckGen, data []ChartData, title string)
 		a2 := a1 + angle
 		mid := (a1 + a2) / 2
 
-		bcolor, op := stdcolor(i, data[i].note, s.Attributes.DataColor, p, s.Flags.SolidPMap)
+		bcolor, op := stdcolor(i, data[i].Note, s.Attributes.DataColor, p, s.Flags.SolidPMap)
+		s.tooltip(deck, data[i].Label, data[i].Value, sum, data[i].Note)
 		deck.Arc(dx, dy, psize, psize, pwidth, a1, a2, bcolor, op)
 		tx, ty := polar(dx, dy, psize*.85, mid*(math.Pi/180))
 		if s.Flags.ShowValues {
-			deck.TextMid(tx, ty, fmt.Sprintf("%s "+s.Attributes.DataFmt+"%%", data[i].label, p), "sans", ts, "")
+			deck.TextMid(tx, ty, fmt.Sprintf("%s "+s.Attributes.DataFmt+"%%", data[i].Label, p), "sans", ts, "")
 		}
 		a1 = a2
 	}
+	if s.Flags.ShowLegend {
+		pcts := pct(data)
+		items := datalegenditems(data, func(i int) (string, float64) {
+			return stdcolor(i, data[i].Note, s.Attributes.DataColor, pcts[i], s.Flags.SolidPMap)
+		})
+		ry := psize * s.aspect() * 0.6
+		s.chartlegend(deck, items, barswatch, dx-psize*0.6, dx+psize*0.6, dy+ry, dy-ry)
+	}
 }
 
 const (
@@ -918,7 +1758,7 @@ func fpolar(cx, cy, r, theta, cw, ch float64) (float64, float64) {
 }
 
 // legend makes a balanced left and right hand legend
-func legend(deck *deckgen.DeckGen, data []ChartData, orientation string, rows int, cx, cy, asize, ts float64) {
+func legend(deck Renderer, data []ChartData, orientation string, rows int, cx, cy, asize, ts float64) {
 	var x, y, xoffset float64
 	var alignment string
 	right := len(data) % rows
@@ -938,8 +1778,8 @@ func legend(deck *deckgen.DeckGen, data []ChartData, orientation string, rows in
 	// left/top legend
 	xoffset = 3
 	for i := 0; i < left; i++ {
-		label := data[i].label
-		deck.Circle(x, y, r, data[i].note)
+		label := data[i].Label
+		deck.Circle(x, y, r, data[i].Note)
 		legendlabel(deck, label, alignment, x+xoffset, y, ts)
 		y -= leading
 	}
@@ -955,15 +1795,15 @@ func legend(deck *deckgen.DeckGen, data []ChartData, orientation string, rows in
 		y = cy - (asize * 0.6)
 	}
 	for i := left; i < len(data); i++ {
-		label := data[i].label
-		deck.Circle(x, y, r, data[i].note)
+		label := data[i].Label
+		deck.Circle(x, y, r, data[i].Note)
 		legendlabel(deck, label, alignment, x+xoffset, y, ts)
 		y -= leading
 	}
 }
 
 // legendlabel lays out the legend labels for fan and bowtie charts
-func legendlabel(deck *deckgen.DeckGen, s, alignment string, x, y, ts float64) {
+func legendlabel(deck Renderer, s, alignment string, x, y, ts float64) {
 	w := strings.Split(s, `\n`)
 	lw := len(w)
 	if lw == 1 {
@@ -978,7 +1818,7 @@ func legendlabel(deck *deckgen.DeckGen, s, alignment string, x, y, ts float64) {
 }
 
 // showtext places text beginning center, or end
-func showtext(deck *deckgen.DeckGen, x, y, ts float64, s, align string) {
+func showtext(deck Renderer, x, y, ts float64, s, align string) {
 	switch align {
 	case "l", "b":
 		deck.Text(x, y, s, "sans", ts, "")
@@ -992,7 +1832,7 @@ func showtext(deck *deckgen.DeckGen, x, y, ts float64, s, align string) {
 }
 
 // arclabel labels the data items
-func arclabel(deck *deckgen.DeckGen, cx, cy, a1, a2, asize, value, cw, ch, ts float64) {
+func arclabel(deck Renderer, cx, cy, a1, a2, asize, value, cw, ch, ts float64) {
 	v := strconv.FormatFloat(value, 'f', 1, 64)
 	diff := a2 - a1
 	lx, ly := fpolar(cx, cy, asize*0.9, a1+(diff*0.5), cw, ch)
@@ -1000,20 +1840,21 @@ func arclabel(deck *deckgen.DeckGen, cx, cy, a1, a2, asize, value, cw, ch, ts fl
 }
 
 // wedge makes data wedges
-func wedge(deck *deckgen.DeckGen, data []ChartData, cx, cy, begAngle, asize, cw, ch, ts float64) {
+func (s *Settings) wedge(deck Renderer, data []ChartData, cx, cy, begAngle, asize, cw, ch, ts float64) {
 	start := begAngle
 	for _, d := range data {
-		m := (d.value / 100) * wingspan
+		m := (d.Value / 100) * wingspan
 		a1 := start
 		a2 := start + m
-		deck.Arc(cx, cy, asize, asize, asize, a1, a2, d.note)
-		arclabel(deck, cx, cy, a1, a2, asize, d.value, cw, ch, ts)
+		s.tooltip(deck, d.Label, d.Value, 0, d.Note)
+		deck.Arc(cx, cy, asize, asize, asize, a1, a2, d.Note)
+		arclabel(deck, cx, cy, a1, a2, asize, d.Value, cw, ch, ts)
 		start = a2
 	}
 }
 
 // bowtie makes a bowtie chart
-func (s *Settings) bowtie(deck *deckgen.DeckGen, data []ChartData, title string) {
+func (s *Settings) bowtie(deck Renderer, data []ChartData, title string) {
 	top := s.Measures.Top
 	left := s.Measures.Left
 	asize := s.Measures.PSize
@@ -1032,10 +1873,10 @@ func (s *Settings) bowtie(deck *deckgen.DeckGen, data []ChartData, title string)
 	//var lx, ly float64
 	//lx, ly = cpolar(cx, cy, asize+1, 180, cw, ch)
 	//deck.TextEnd(lx, ly, "", "sans", ts, s.LabelColor)
-	wedge(deck, topdata, cx, cy, leftbegAngle, asize, cw, ch, ts)
+	s.wedge(deck, topdata, cx, cy, leftbegAngle, asize, cw, ch, ts)
 	//lx, ly = cpolar(cx, cy, asize+1, 0, cw, ch)
 	//deck.TextEnd(lx, ly, "", "sans", ts, s.LabelColor)
-	wedge(deck, botdata, cx, cy, rightbegAngle, asize, cw, ch, ts)
+	s.wedge(deck, botdata, cx, cy, rightbegAngle, asize, cw, ch, ts)
 
 	ty := cy + (asize * 1.2)
 	if s.Flags.ShowValues {
@@ -1048,7 +1889,7 @@ func (s *Settings) bowtie(deck *deckgen.DeckGen, data []ChartData, title string)
 }
 
 // fan makes a fan chart
-func (s *Settings) fan(deck *deckgen.DeckGen, data []ChartData, title string) {
+func (s *Settings) fan(deck Renderer, data []ChartData, title string) {
 	top := s.Measures.Top
 	left := s.Measures.Left
 	asize := s.Measures.PSize
@@ -1070,22 +1911,24 @@ func (s *Settings) fan(deck *deckgen.DeckGen, data []ChartData, title string) {
 	// the top of the fan chart
 	start = topbegAngle
 	for _, d := range topdata {
-		m := (d.value / 100) * fanspan
+		m := (d.Value / 100) * fanspan
 		a1 := start - m
 		a2 := start
-		deck.Arc(cx, cy, asize, asize, asize, a1, a2, d.note)
-		arclabel(deck, cx, cy, a1, a2, asize, d.value, cw, ch, ts)
+		s.tooltip(deck, d.Label, d.Value, 0, d.Note)
+		deck.Arc(cx, cy, asize, asize, asize, a1, a2, d.Note)
+		arclabel(deck, cx, cy, a1, a2, asize, d.Value, cw, ch, ts)
 		start = a1
 	}
 	// bottom of the fan chart
 	start = botbegAngle
 	for i := len(botdata) - 1; i >= 0; i-- {
 		d := botdata[i]
-		m := (d.value / 100) * fanspan
+		m := (d.Value / 100) * fanspan
 		a1 := start + m
 		a2 := start
-		deck.Arc(cx, cy, asize, asize, asize, a2, a1, d.note)
-		arclabel(deck, cx, cy, a1, a2, asize, d.value, cw, ch, ts)
+		s.tooltip(deck, d.Label, d.Value, 0, d.Note)
+		deck.Arc(cx, cy, asize, asize, asize, a2, a1, d.Note)
+		arclabel(deck, cx, cy, a1, a2, asize, d.Value, cw, ch, ts)
 		start = a1
 	}
 
@@ -1095,13 +1938,36 @@ func (s *Settings) fan(deck *deckgen.DeckGen, data []ChartData, title string) {
 }
 
 // Pchart draws proportional data, either a pmap, pgrid, radial or donut using input from a Reader
-func (s *Settings) Pchart(deck *deckgen.DeckGen, r io.ReadCloser) {
+func (s *Settings) Pchart(deck Renderer, r io.ReadCloser) error {
+	ds, err := s.dataset(r)
+	if err != nil {
+		return err
+	}
+	return s.pchart(deck, ds)
+}
+
+// pchart draws proportional data from a dataset
+func (s *Settings) pchart(deck Renderer, ds Dataset) error {
 	f := s.Flags
-	data, _, maxdata, title := Getdata(r, f.ReadCSV, s.Attributes.CSVCols)
+	data, maxdata, title := ds.Data, ds.Max, ds.Title
 	chartitle := s.Attributes.ChartTitle
 	if len(chartitle) > 0 {
 		title = xmlesc(chartitle)
 	}
+	// conditional colors are the colors of the data, in place of the annotation
+	// (except in grouped treemaps, where annotations are the item labels)
+	rules, err := s.colorrules()
+	if err != nil {
+		return err
+	}
+	if rules.active() && !(f.ShowTreemap && f.Grouped) {
+		colored := make([]ChartData, len(data))
+		for i, d := range data {
+			d.Note = rules.color(d, d.Value, d.Note)
+			colored[i] = d
+		}
+		data = colored
+	}
 	if f.FullDeck {
 		deck.StartSlide(s.Attributes.BackgroundColor)
 	}
@@ -1110,6 +1976,8 @@ func (s *Settings) Pchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 		s.donut(deck, data, title)
 	case f.ShowPMap:
 		s.pmap(deck, data, title)
+	case f.ShowTreemap:
+		s.treemap(deck, data, title)
 	case f.ShowBowtie:
 		s.bowtie(deck, data, title)
 	case f.ShowFan:
@@ -1124,10 +1992,20 @@ func (s *Settings) Pchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	if f.FullDeck {
 		deck.EndSlide()
 	}
+	return nil
 }
 
 // Wbchart makes a word bar chart
-func (s *Settings) Wbchart(deck *deckgen.DeckGen, r io.ReadCloser) {
+func (s *Settings) Wbchart(deck Renderer, r io.ReadCloser) error {
+	ds, err := s.dataset(r)
+	if err != nil {
+		return err
+	}
+	return s.wbchart(deck, ds)
+}
+
+// wbchart makes a word bar chart from a dataset
+func (s *Settings) wbchart(deck Renderer, ds Dataset) error {
 	left := s.Measures.Left
 	right := s.Measures.Right
 	top := s.Measures.Top
@@ -1144,7 +2022,7 @@ func (s *Settings) Wbchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	mts := ts * 0.75
 	linespacing := ts * ls
 
-	bardata, mindata, maxdata, title := Getdata(r, s.Flags.ReadCSV, s.Attributes.CSVCols) // getdata(r)
+	bardata, mindata, maxdata, title := ds.Data, ds.Min, ds.Max, ds.Title
 	if !datamin {
 		mindata = 0
 	}
@@ -1160,22 +2038,12 @@ func (s *Settings) Wbchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 		deck.Text(left, top+(linespacing*1.5), title, "sans", ts*1.5, Titlecolor)
 	}
 
-	var sum float64
-	if s.Flags.ShowPercentage {
-		sum = datasum(bardata)
-	}
+	sum := datasum(bardata)
 
 	// check for conditional data
-	var clow, chigh float64
-	var cerr error
-	var condcolor string
-	datacond := s.Attributes.DataCondition
-	if len(datacond) > 0 {
-		clow, chigh, condcolor, cerr = parsecondition(datacond)
-		if cerr != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", cerr)
-			return
-		}
+	rules, err := s.colorrules()
+	if err != nil {
+		return err
 	}
 
 	// for every name, value pair, make the chart
@@ -1183,36 +2051,46 @@ func (s *Settings) Wbchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	labelcolor, datacolor, valuecolor := s.Attributes.LabelColor, s.Attributes.DataColor, s.Attributes.ValueColor
 	defcolor := datacolor
 	for _, data := range bardata {
-		deck.Text(left+hts, y, data.label, "sans", ts, labelcolor)
-		bv := vmap(data.value, mindata, maxdata, left, right)
+		deck.Text(left+hts, y, data.Label, "sans", ts, labelcolor)
+		bv := vmap(data.Value, mindata, maxdata, left, right)
 
-		if len(datacond) > 0 {
-			if data.value <= chigh && data.value >= clow {
-				datacolor = condcolor
-			} else {
-				datacolor = defcolor
-			}
+		if rules.active() {
+			datacolor = rules.color(data, data.Value, defcolor)
 		}
 
+		s.tooltip(deck, data.Label, data.Value, sum, data.Note)
 		deck.Line(left+hts, y+hts, bv, y+hts, ts*1.5, datacolor, wbop)
 		if s.Flags.ShowValues {
 			df := s.Attributes.DataFmt
 			if s.Flags.ShowPercentage {
-				avgs := fmt.Sprintf(" ("+df+"%%)", 100*(data.value/sum))
-				deck.TextEnd(left, y+(hts/2), dformat(df, data.value)+avgs, "mono", mts, valuecolor)
+				avgs := fmt.Sprintf(" ("+df+"%%)", 100*(data.Value/sum))
+				deck.TextEnd(left, y+(hts/2), dformat(df, data.Value)+avgs, "mono", mts, valuecolor)
 			} else {
-				deck.TextEnd(left, y+(hts/2), dformat(df, data.value), "mono", mts, valuecolor)
+				deck.TextEnd(left, y+(hts/2), dformat(df, data.Value), "mono", mts, valuecolor)
 			}
 		}
 		y -= linespacing
 	}
+	if s.Flags.ShowLegend {
+		s.chartlegend(deck, s.condlegenditems(defcolor), barswatch, left, right, top+linespacing, y+linespacing)
+	}
 	if s.Flags.FullDeck {
 		deck.EndSlide()
 	}
+	return nil
 }
 
 // Hchart makes horizontal bar charts using input from a Reader
-func (s *Settings) Hchart(deck *deckgen.DeckGen, r io.ReadCloser) {
+func (s *Settings) Hchart(deck Renderer, r io.ReadCloser) error {
+	ds, err := s.dataset(r)
+	if err != nil {
+		return err
+	}
+	return s.hchart(deck, ds)
+}
+
+// hchart makes horizontal bar charts from a dataset
+func (s *Settings) hchart(deck Renderer, ds Dataset) error {
 	ts := s.Measures.TextSize
 	ls := s.Measures.LineSpacing
 	left := s.Measures.Left
@@ -1223,17 +2101,31 @@ func (s *Settings) Hchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	mts := ts * 0.75
 	linespacing := ts * ls
 
-	bardata, mindata, maxdata, title := Getdata(r, s.Flags.ReadCSV, s.Attributes.CSVCols) // getdata(r)
+	bardata, mindata, maxdata, title := ds.Data, ds.Min, ds.Max, ds.Title
 
 	if left < 0 {
 		left = 30.0
 	}
 
 	f := s.Flags
-	if !f.DataMinimum {
+	ns := nseries(bardata)
+	stack := f.ShowStack || f.StackPercent
+	if stack {
+		mindata, maxdata = stackrange(bardata, ns, f.StackPercent)
+	}
+	if !f.DataMinimum && !stack {
 		mindata = 0
 	}
 
+	if f.LogScale {
+		var err error
+		mindata, maxdata, err = s.logrange(bardata, mindata, maxdata)
+		if err != nil {
+			return err
+		}
+	}
+	mindata, maxdata = expandrange(mindata, maxdata)
+
 	bgcolor := s.Attributes.BackgroundColor
 	valuecolor := s.Attributes.ValueColor
 	datacolor := s.Attributes.DataColor
@@ -1250,74 +2142,174 @@ func (s *Settings) Hchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	}
 
 	if len(title) > 0 && f.ShowTitle {
-		deck.TextMid(50, top+(linespacing*1.5), title, "sans", ts*1.5, Titlecolor)
+		deck.TextMid(left+(right-left)/2, top+(linespacing*1.5), title, "sans", ts*1.5, Titlecolor)
 	}
 
-	var sum float64
-	if f.ShowPercentage {
-		sum = datasum(bardata)
-	}
+	sum := datasum(bardata)
 
 	// check for conditional data
-	var clow, chigh float64
-	var cerr error
-	var condcolor string
-	datacond := s.Attributes.DataCondition
-	if len(datacond) > 0 {
-		clow, chigh, condcolor, cerr = parsecondition(datacond)
-		if cerr != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", cerr)
-			return
+	rules, err := s.colorrules()
+	if err != nil {
+		return err
+	}
+
+	bw := ts
+	barw := s.Measures.BarWidth
+	if barw > 0 {
+		bw = barw
+	}
+
+	// reference lines and bands are across the rows of bars
+	refs, err := s.parsereferences()
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 && len(bardata) > 0 {
+		rows := make([]float64, len(bardata))
+		for i := range rows {
+			rows[i] = top + hts - float64(i)*linespacing
 		}
+		value := func(v float64) float64 { return s.scale(v, mindata, maxdata, left, right) }
+		s.drawreferences(deck, refs, value, s.labelposition(bardata, rows, nil, 0, 0), true, left, right, rows[len(rows)-1]-linespacing/2, top+hts+linespacing/2)
 	}
 
 	// for every name, value pair, make the chart
 	y := top
 
+	colors := s.seriescolors(ns)
+	if stack && ns > 1 && !f.ShowLegend {
+		s.serieslegend(deck, ns, colors, left, top+linespacing*0.75)
+	}
 	for _, data := range bardata {
-		label := nlmap.Replace(data.label) // replace '\n' with spaces
+		label := nlmap.Replace(data.Label) // replace '\n' with spaces
 		deck.TextEnd(left-hts, y+(hts/2), label, "sans", ts, labelcolor)
-		bv := vmap(data.value, mindata, maxdata, left, right)
+		if stack {
+			s.hstack(deck, data, ns, colors, rules, mindata, maxdata, left, y+hts, bw)
+			y -= linespacing
+			continue
+		}
+		bv := s.scale(data.Value, mindata, maxdata, left, right)
 
-		if len(datacond) > 0 {
-			if data.value <= chigh && data.value >= clow {
-				datacolor = condcolor
-			} else {
-				datacolor = defcolor
-			}
+		if rules.active() {
+			datacolor = rules.color(data, data.Value, defcolor)
 		}
 
 		if f.ShowDot {
 			dottedhline(deck, left, y+hts, bv-left, ts/5, 1, 0.25, Dotlinecolor)
+			s.tooltip(deck, data.Label, data.Value, sum, data.Note)
 			deck.Circle(bv, y+hts, mts, datacolor)
 		} else {
-			bw := ts
-			barw := s.Measures.BarWidth
-			if barw > 0 {
-				bw = barw
-			}
+			s.tooltip(deck, data.Label, data.Value, sum, data.Note)
 			deck.Line(left, y+hts, bv, y+hts, bw, datacolor)
 		}
 		if f.ShowValues {
 			df := s.Attributes.DataFmt
 			if f.ShowPercentage {
-				avgs := fmt.Sprintf(" ("+df+"%%)", 100*(data.value/sum))
-				deck.Text(bv+hts, y+(hts/2), dformat(df, data.value)+avgs, "mono", mts, valuecolor)
+				avgs := fmt.Sprintf(" ("+df+"%%)", 100*(data.Value/sum))
+				deck.Text(bv+hts, y+(hts/2), dformat(df, data.Value)+avgs, "mono", mts, valuecolor)
 			} else {
-				deck.Text(bv+hts, y+(hts/2), dformat(df, data.value), "mono", mts, valuecolor)
+				deck.Text(bv+hts, y+(hts/2), dformat(df, data.Value), "mono", mts, valuecolor)
 			}
 		}
 		y -= linespacing
 	}
+	// regressions of the values on the order of the bars, one for each stacked series
+	if f.ShowRegressionLine && len(bardata) > 0 {
+		nreg := 1
+		if stack && !f.StackPercent {
+			nreg = ns
+		}
+		n := float64(len(bardata) - 1)
+		point := func(x, v float64) (float64, float64) {
+			return s.scale(v, mindata, maxdata, left, right), vmap(x, 0, n, top+hts, top+hts-n*linespacing)
+		}
+		x := make([]float64, len(bardata))
+		for i := range x {
+			x[i] = float64(i)
+		}
+		for k := 0; k < nreg; k++ {
+			v := make([]float64, len(bardata))
+			for i, data := range bardata {
+				if k < len(data.Values) {
+					v[i] = data.Values[k]
+				}
+			}
+			color := s.Attributes.RegressionLineColor
+			if nreg > 1 {
+				color = colors[k]
+			}
+			// statistics are below the bars
+			reg, ok := s.rline(deck, x, v, mindata, maxdata, point, color)
+			if ok && f.ShowRegressionStats {
+				deck.Text(left, y-float64(k)*mts*1.5, reg.String(), "sans", mts*0.8, color)
+			}
+		}
+	}
+	if f.ShowLegend {
+		swatch := barswatch
+		if f.ShowDot && !stack {
+			swatch = dotswatch
+		}
+		s.chartlegend(deck, s.valuelegenditems(ns, colors), swatch, left, right, top+linespacing, y+linespacing)
+	}
 	if f.FullDeck {
 		deck.EndSlide()
 	}
+	return nil
+}
+
+// hstack draws a horizontal stacked bar at y, with a segment per series
+// colored by the series colors or the conditional color rules,
+// and values in the middle of each segment
+func (s *Settings) hstack(deck Renderer, data ChartData, ns int, colors []string, rules colorrules, mindata, maxdata, left, y, bw float64) {
+	right := s.Measures.Right
+	ts := s.Measures.TextSize
+	df := s.Attributes.DataFmt
+	lo, hi := stackrow(data, ns, s.Flags.StackPercent)
+	var rowpct []float64
+	if s.Flags.ShowPercentage {
+		rowpct = pct(rowdata(data))
+	}
+	rowsum := datasum(rowdata(data))
+	names := s.seriesnames(ns)
+	for k := 0; k < ns && k < len(data.Values); k++ {
+		value := data.Values[k]
+		x1 := s.scale(lo[k], mindata, maxdata, left, right)
+		x2 := s.scale(hi[k], mindata, maxdata, left, right)
+		color := rules.color(data, value, colors[k])
+		s.tooltip(deck, data.Label+" "+names[k], value, rowsum, data.Note)
+		deck.Line(x1, y, x2, y, bw, color)
+		if s.Flags.ShowValues {
+			vs := dformat(df, value)
+			if s.Flags.StackPercent {
+				vs = dformat(df, hi[k]-lo[k]) + "%"
+			} else if s.Flags.ShowPercentage {
+				vs += fmt.Sprintf(" ("+df+"%%)", rowpct[k])
+			}
+			deck.TextMid(x1+((x2-x1)/2), y-(ts/4), vs, "mono", ts*0.5, s.Attributes.ValueColor)
+		}
+	}
 }
 
 // Vchart makes charts using input from a Reader
 // the types of charts are bar (column), dot, line, and volume
-func (s *Settings) Vchart(deck *deckgen.DeckGen, r io.ReadCloser) {
-	chartdata, mindata, maxdata, title := Getdata(r, s.Flags.ReadCSV, s.Attributes.CSVCols) // getdata(r)
+func (s *Settings) Vchart(deck Renderer, r io.ReadCloser) error {
+	ds, err := s.dataset(r)
+	if err != nil {
+		return err
+	}
+	return s.vchart(deck, ds)
+}
+
+// vchart makes bar (column), dot, line, and volume charts from a dataset
+func (s *Settings) vchart(deck Renderer, ds Dataset) error {
+	if s.Flags.ShowXY {
+		return s.xychart(deck, ds)
+	}
+	if s.Flags.ShowHistogram {
+		return s.histogram(deck, ds)
+	}
+	chartdata, mindata, maxdata, title := ds.Data, ds.Min, ds.Max, ds.Title
 
 	left := s.Measures.Left
 	right := s.Measures.Right
@@ -1346,17 +2338,88 @@ func (s *Settings) Vchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 	valuecolor := s.Attributes.ValueColor
 	valpos := s.Attributes.ValuePosition
 	noteloc := s.Attributes.NoteLocation
-	hline := s.Attributes.HLine
-	datacolor := s.Attributes.DataColor
 	labelcolor := s.Attributes.LabelColor
 	framecolor := s.Attributes.FrameColor
 	linewidth := s.Measures.LineWidth
 
+	ns := nseries(chartdata)
+	stack := s.Flags.ShowStack || s.Flags.StackPercent
+	if stack {
+		mindata, maxdata = stackrange(chartdata, ns, s.Flags.StackPercent)
+	}
+
+	// waterfall bars float from the running total, of a single series
+	wf := s.Flags.ShowWaterfall
+	if wf && ns > 1 {
+		err := fmt.Errorf("waterfall charts need a single series, not %d", ns)
+		if s.Flags.StrictData {
+			return err
+		}
+		s.Warnings = append(s.Warnings, fmt.Errorf("%v, bars are shown instead", err))
+		wf = false
+	}
+	var wfstart, wfend []float64
+	var wfkind []int
+	if wf {
+		wfstart, wfend, wfkind = waterfall(chartdata)
+		mindata, maxdata = minmax(wfstart, largest, smallest)
+		mindata, maxdata = minmax(wfend, mindata, maxdata)
+		showbar = true
+	}
+
+	// candlestick and OHLC charts use the range of prices (open, high, low, close),
+	// optionally with volume in a panel below the prices: the series after
+	// the prices named volume (with -series or -csvcol)
+	candle := s.Flags.ShowCandle || s.Flags.ShowOHLC
+	vi := -1
+	if candle && s.Flags.ShowCandleVolume {
+		for i, name := range s.seriesnames(ns) {
+			if i >= 4 && strings.EqualFold(name, "volume") {
+				vi = i
+				break
+			}
+		}
+		if vi < 0 {
+			s.Warnings = append(s.Warnings, errors.New("no volume is shown without a series named volume (-series or -csvcol)"))
+		}
+	}
+	showcvol := vi >= 0
+	labelbottom := bottom
+	var maxvol, volbottom, voltop float64
+	if candle {
+		mindata, maxdata = largest, smallest
+		for _, d := range chartdata {
+			if len(d.Values) >= 4 {
+				mindata, maxdata = minmax(d.Values[0:4], mindata, maxdata)
+			}
+			if showcvol && len(d.Values) > vi && d.Values[vi] > maxvol {
+				maxvol = d.Values[vi]
+			}
+		}
+		datamin = true
+	}
+	if showcvol {
+		volbottom = bottom
+		bottom += (top - bottom) * candlevolume
+		voltop = bottom - ts
+		defer func(b float64) { s.Measures.Bottom = b }(s.Measures.Bottom)
+		s.Measures.Bottom = bottom
+	}
+
 	if left < 0 {
 		left = 10.0
 	}
 
-	if !datamin {
+	overlays, err := s.parseoverlays(ns)
+	if err != nil {
+		return err
+	}
+	refs, err := s.parsereferences()
+	if err != nil {
+		return err
+	}
+
+	if !datamin && !stack && !wf {
 		mindata = 0
 	}
 
@@ -1368,30 +2431,74 @@ func (s *Settings) Vchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 		maxdata = umax
 	}
 
+	if s.Flags.LogScale {
+		var err error
+		mindata, maxdata, err = s.logrange(chartdata, mindata, maxdata)
+		if err != nil {
+			return err
+		}
+	}
+	mindata, maxdata = expandrange(mindata, maxdata)
+
 	l := len(chartdata)
-	dlen := float64(l - 1)
+	colors := s.seriescolors(ns)
+
+	// x positions are evenly spaced, or proportional to time
+	var times []time.Time
+	if s.Flags.ShowTimeAxis {
+		var err error
+		times, err = s.parsetimes(chartdata)
+		if err != nil {
+			return err
+		}
+	}
+	xpos, xgap := s.xpositions(chartdata, times, left, right)
 
 	// define the width of bars
-	var dw = (right-left)/dlen - 1
+	var dw = xgap - 1
+	if times != nil {
+		dw = xgap * 0.8
+	}
 	if barw > 0 && barw <= dw {
 		dw = barw
 	}
+	// grouped bars share the width of a single bar
+	bw := dw / float64(ns)
+	if stack {
+		bw = dw
+	}
 
 	// for volume plots, allocate, fill in the extrema
-	var xvol, yvol []float64
+	// stacked volumes keep the lower boundary of each series
+	var xvol, yvol, lvol [][]float64
 	if showvolume {
-		xvol = make([]float64, l+2)
-		yvol = make([]float64, l+2)
-		xvol[0] = left
-		yvol[0] = bottom
-		xvol[l+1] = right
-		yvol[l+1] = bottom
+		xvol = make([][]float64, ns)
+		yvol = make([][]float64, ns)
+		lvol = make([][]float64, ns)
+		for k := 0; k < ns; k++ {
+			xvol[k] = make([]float64, l+2)
+			yvol[k] = make([]float64, l+2)
+			lvol[k] = make([]float64, l+2)
+			xvol[k][0] = left
+			yvol[k][0] = bottom
+			xvol[k][l+1] = right
+			yvol[k][l+1] = bottom
+		}
 	}
 
-	var xreg, yreg []float64
+	// one regression for each series, or for the closing prices
+	var xreg []float64
+	var yreg [][]float64
 	if showrline {
+		nreg := ns
+		if candle {
+			nreg = 1
+		}
 		xreg = make([]float64, l)
-		yreg = make([]float64, l)
+		yreg = make([][]float64, nreg)
+		for k := range yreg {
+			yreg[k] = make([]float64, l)
+		}
 	}
 
 	linespacing := ts * ls
@@ -1423,143 +2530,439 @@ func (s *Settings) Vchart(deck *deckgen.DeckGen, r io.ReadCloser) {
 		s.yaxis(deck, left-spacing, mindata, maxdata)
 	}
 
-	if len(hline) > 0 {
-		var hl float64
-		var hs string
-		fmt.Sscanf(hline, "%f,%s", &hl, &hs)
-		hy := vmap(hl, mindata, maxdata, bottom, top)
-		deck.Line(left, hy, right, hy, 0.1, valuecolor, 50)
-		if len(hs) > 0 {
-			deck.Text(right+ts/2, hy-ts/4, hs, "serif", ts*0.75, labelcolor)
-		}
+	if len(refs) > 0 {
+		value := func(v float64) float64 { return s.scale(v, mindata, maxdata, bottom, top) }
+		s.drawreferences(deck, refs, value, s.labelposition(chartdata, xpos, times, left, right), false, left, right, bottom, top)
 	}
 
-	var clow, chigh float64
-	var cerr error
-	var condcolor string
-	datacond := s.Attributes.DataCondition
-	if len(datacond) > 0 {
-		clow, chigh, condcolor, cerr = parsecondition(datacond)
-		if cerr != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", cerr)
-			return
-		}
+	rules, err := s.colorrules()
+	if err != nil {
+		return err
 	}
 
-	var sum float64
-	if showpct {
-		sum = datasum(chartdata)
-	}
+	sum := datasum(chartdata)
+	names := s.seriesnames(ns)
 
 	// for every name, value pair, make the chart elements
-	var px, py float64
-	var defcolor = datacolor
+	px := make([]float64, ns)
+	py := make([]float64, ns)
 	for i, data := range chartdata {
-		x := vmap(float64(i), 0, dlen, left, right)
-		y := vmap(data.value, mindata, maxdata, bottom, top)
-
-		if showvolume {
-			xvol[i+1] = x
-			yvol[i+1] = y
+		x := xpos[i]
+		y := s.scale(data.Value, mindata, maxdata, bottom, top)
+		if wf {
+			y = s.scale(math.Max(wfstart[i], wfend[i]), mindata, maxdata, bottom, top)
 		}
 
 		if showrline {
 			xreg[i] = float64(i)
-			yreg[i] = data.value
+			if times != nil {
+				xreg[i] = float64(times[i].Unix())
+			}
+			for k := range yreg {
+				c := k
+				if candle {
+					c = 3
+				}
+				if c < len(data.Values) {
+					yreg[k][i] = data.Values[c]
+				}
+			}
 		}
 
-		if len(datacond) > 0 {
-			if data.value <= chigh && data.value >= clow {
-				datacolor = condcolor
-			} else {
-				datacolor = defcolor
+		var lo, hi, rowpct []float64
+		if stack {
+			lo, hi = stackrow(data, ns, s.Flags.StackPercent)
+			if showpct {
+				rowpct = pct(rowdata(data))
 			}
 		}
-		if showline && i > 0 {
-			deck.Line(px, py, x, y, linewidth, datacolor)
-		}
 
-		if showdot {
-			dottedvline(deck, x, bottom, y, ts/6, 1, Dotlinecolor)
-			deck.Circle(x, y, ts*.6, datacolor)
+		if candle {
+			s.candlestick(deck, data, x, xgap*0.6, mindata, maxdata)
+			if showcvol && len(data.Values) > vi {
+				vy := vmap(data.Values[vi], 0, maxvol, volbottom, voltop)
+				deck.Line(x, volbottom, x, vy, xgap*0.6, s.updown(data), 50)
+			}
 		}
 
-		if showscatter {
-			deck.Circle(x, y, ts*.6, datacolor)
+		// draw every series, one color per series;
+		// tooltips show the percentage of the row for multiple series
+		tiplabel, tipsum := data.Label, sum
+		if ns > 1 {
+			tipsum = datasum(rowdata(data))
 		}
+		for k := 0; k < ns && !candle; k++ {
+			value := 0.0
+			if k < len(data.Values) {
+				value = data.Values[k]
+			}
+			if ns > 1 {
+				tiplabel = data.Label + " " + names[k]
+			}
+			yb, sy := bottom, s.scale(value, mindata, maxdata, bottom, top)
+			if stack {
+				yb, sy = s.scale(lo[k], mindata, maxdata, bottom, top), s.scale(hi[k], mindata, maxdata, bottom, top)
+				if value < 0 {
+					yb, sy = sy, yb
+				}
+			}
+			datacolor := colors[k]
+			if wf {
+				value = wfend[i] - wfstart[i]
+				yb, sy = s.scale(wfstart[i], mindata, maxdata, bottom, top), s.scale(wfend[i], mindata, maxdata, bottom, top)
+				datacolor = s.waterfallcolor(wfkind[i])
+			}
+			datacolor = rules.color(data, value, datacolor)
 
-		if showbar {
-			deck.Line(x, bottom, x, y, dw, datacolor)
-		}
+			if showvolume {
+				xvol[k][i+1] = x
+				yvol[k][i+1] = sy
+				lvol[k][i+1] = yb
+			}
 
-		if showval {
-			yv := y + ts
-			switch valpos {
-			case "t":
-				if data.value < 0 {
-					yv = y - ts
-				} else {
-					yv = y + ts
+			if showline && i > 0 {
+				deck.Line(px[k], py[k], x, sy, linewidth, datacolor)
+			}
+
+			if showdot {
+				dottedvline(deck, x, bottom, sy, ts/6, 1, Dotlinecolor)
+				s.tooltip(deck, tiplabel, value, tipsum, data.Note)
+				deck.Circle(x, sy, ts*.6, datacolor)
+			}
+
+			if showscatter {
+				s.tooltip(deck, tiplabel, value, tipsum, data.Note)
+				deck.Circle(x, sy, ts*.6, datacolor)
+			}
+
+			bx := x
+			if showbar {
+				if !stack {
+					bx = x - (dw / 2) + (bw * (float64(k) + 0.5))
+				}
+				s.tooltip(deck, tiplabel, value, tipsum, data.Note)
+				deck.Line(bx, yb, bx, sy, bw, datacolor)
+				// connect the running total to the previous bar
+				if wf && i > 0 {
+					cy := s.scale(wfend[i-1], mindata, maxdata, bottom, top)
+					deck.Line(px[k]+bw/2, cy, bx-bw/2, cy, 0.1, labelcolor)
 				}
-			case "b":
-				yv = bottom + ts
-			case "m":
-				yv = y - ((y - bottom) / 2)
 			}
-			df := s.Attributes.DataFmt
-			if showpct {
-				avgs := fmt.Sprintf(" ("+df+"%%)", 100*(data.value/sum))
-				deck.TextMid(x, yv, dformat(df, data.value)+avgs, "sans", ts*0.75, valuecolor)
-			} else {
-				deck.TextMid(x, yv, dformat(df, data.value), "sans", ts*0.75, valuecolor)
+
+			// stacked values are placed in the middle of their segment
+			if showval && stack {
+				df := s.Attributes.DataFmt
+				vs := dformat(df, value)
+				if s.Flags.StackPercent {
+					vs = dformat(df, hi[k]-lo[k]) + "%"
+				} else if showpct {
+					vs += fmt.Sprintf(" ("+df+"%%)", rowpct[k])
+				}
+				deck.TextMid(bx, yb+((sy-yb)/2)-(ts/4), vs, "sans", ts*0.75, valuecolor)
+			}
+
+			if showval && !stack {
+				yv := sy + ts
+				switch valpos {
+				case "t":
+					if value < 0 {
+						yv = sy - ts
+					} else {
+						yv = sy + ts
+					}
+				case "b":
+					yv = bottom + ts
+				case "m":
+					yv = sy - ((sy - yb) / 2)
+				}
+				df := s.Attributes.DataFmt
+				if showpct && ns == 1 {
+					avgs := fmt.Sprintf(" ("+df+"%%)", 100*(value/sum))
+					deck.TextMid(bx, yv, dformat(df, value)+avgs, "sans", ts*0.75, valuecolor)
+				} else {
+					deck.TextMid(bx, yv, dformat(df, value), "sans", ts*0.75, valuecolor)
+				}
 			}
+			px[k] = x
+			py[k] = sy
 		}
-		if len(data.note) > 0 && shownote {
+
+		if len(data.Note) > 0 && shownote && !(wf && istotal(data.Note)) {
 			xoffset := ts / 2
 			yoffset := ts / 2
 			notesize := ts * 0.75
 			switch noteloc {
 			case "l", "b":
-				deck.Text(x+xoffset, y, data.note, "serif", notesize, labelcolor)
+				deck.Text(x+xoffset, y, data.Note, "serif", notesize, labelcolor)
 			case "r", "e":
-				deck.TextEnd(x-xoffset, y, data.note, "serif", notesize, labelcolor)
+				deck.TextEnd(x-xoffset, y, data.Note, "serif", notesize, labelcolor)
 			case "c":
-				deck.TextMid(x, y+yoffset, data.note, "serif", notesize, labelcolor)
+				deck.TextMid(x, y+yoffset, data.Note, "serif", notesize, labelcolor)
 			default:
-				deck.TextMid(x, y+yoffset, data.note, "serif", notesize, labelcolor)
+				deck.TextMid(x, y+yoffset, data.Note, "serif", notesize, labelcolor)
 			}
 		}
 		// Show x label every xinit times, Show the last, if specified
+		// (time axes are labeled with ticks)
 		xint := s.Measures.XLabelInterval
-		if xint > 0 && (i%xint == 0 || (s.Flags.ShowXLast && i == l-1)) {
-			xlabels := strings.Split(data.label, `\n`)
-			xly := bottom - (ts * 2)
-			for _, xl := range xlabels {
-				if s.Flags.ShowXstagger && (i+1)%2 == 0 {
-					xly -= (ts * 2)
-				}
-				if s.Measures.XLabelRotation == 0 {
-					deck.TextMid(x, xly, xl, "sans", ts*0.8, labelcolor)
-				} else {
-					deck.TextRotate(x, xly, xl, "", "sans", s.Measures.XLabelRotation, ts*0.8, labelcolor)
-				}
-				xly -= ts * 1.2
-			}
+		if times == nil && xint > 0 && (i%xint == 0 || (s.Flags.ShowXLast && i == l-1)) {
+			s.xlabel(deck, x, labelbottom, data.Label, i)
 		}
-		px = x
-		py = y
+	}
+	if times != nil && s.Measures.XLabelInterval > 0 {
+		s.timeaxis(deck, times, left, right, labelbottom)
 	}
 	if showvolume {
-		deck.Polygon(xvol, yvol, datacolor, s.Measures.VolumeOpacity)
+		for k := 0; k < ns; k++ {
+			if stack {
+				xs, ys := stackpoly(xvol[k][1:l+1], yvol[k][1:l+1], lvol[k][1:l+1])
+				deck.Polygon(xs, ys, colors[k], s.Measures.VolumeOpacity)
+				continue
+			}
+			deck.Polygon(xvol[k], yvol[k], colors[k], s.Measures.VolumeOpacity)
+		}
 	}
 
 	if showrline {
-		s.Measures.rline(deck, xreg, yreg, mindata, maxdata, s.Attributes.RegressionLineColor)
+		xmin, xmax := 0.0, float64(l-1)
+		if times != nil {
+			tmin, tmax := timespan(times)
+			xmin, xmax = float64(tmin.Unix()), float64(tmax.Unix())
+		}
+		point := func(x, y float64) (float64, float64) {
+			return vmap(x, xmin, xmax, left, right), s.scale(y, mindata, maxdata, bottom, top)
+		}
+		// statistics are above the end of each line
+		for k := range yreg {
+			color := s.Attributes.RegressionLineColor
+			if len(yreg) > 1 {
+				color = colors[k]
+			}
+			reg, ok := s.rline(deck, xreg, yreg[k], mindata, maxdata, point, color)
+			if ok && s.Flags.ShowRegressionStats {
+				ex, ey := point(xmax, reg.at(xmax))
+				deck.TextEnd(ex, ey+ts*0.6, reg.String(), "sans", ts*0.6, color)
+			}
+		}
+	}
+
+	// overlays follow the first series, or the closing prices
+	if len(overlays) > 0 {
+		values := make([]float64, l)
+		for i, d := range chartdata {
+			values[i] = d.Value
+			if candle && len(d.Values) >= 4 {
+				values[i] = d.Values[3]
+			}
+		}
+		s.drawoverlays(deck, overlays, values, times, mindata, maxdata)
+	}
+
+	if showcvol {
+		deck.TextEnd(left-spacing, voltop-(ts/4), dformat(s.Attributes.DataFmt, maxvol), "sans", ts*0.75, labelcolor)
+		deck.Line(left, voltop, right, voltop, 0.1, "lightgray")
+	}
+
+	switch {
+	case s.Flags.ShowLegend || len(overlays) > 0:
+		swatch := dotswatch
+		switch {
+		case showbar || showvolume:
+			swatch = barswatch
+		case showline:
+			swatch = lineswatch
+		}
+		var items []legenditem
+		switch {
+		case wf:
+			items = s.waterfalllegenditems()
+		case !candle:
+			items = s.valuelegenditems(ns, colors)
+		}
+		items = append(items, overlaylegenditems(overlays)...)
+		s.chartlegend(deck, items, swatch, left, right, top, labelbottom-spacing*2)
+	case ns > 1 && !candle:
+		s.serieslegend(deck, ns, colors, left, top+linespacing*0.5)
 	}
 
 	if s.Flags.FullDeck {
 		deck.EndSlide()
 	}
+	return nil
+}
+
+// updown returns the color of a price interval,
+// the up color if the close is at or above the open, otherwise the down color
+func (s *Settings) updown(d ChartData) string {
+	if len(d.Values) >= 4 && d.Values[3] < d.Values[0] {
+		return s.Attributes.DownColor
+	}
+	return s.Attributes.UpColor
+}
+
+// candlestick draws a price interval (open, high, low, close) at x,
+// either as a candle with a wick and body, or as an OHLC bar with open and close ticks
+func (s *Settings) candlestick(deck Renderer, d ChartData, x, w, mindata, maxdata float64) {
+	if len(d.Values) < 4 {
+		return
+	}
+	bottom := s.Measures.Bottom
+	top := s.Measures.Top
+	lw := s.Measures.LineWidth / 2
+	yo := s.scale(d.Values[0], mindata, maxdata, bottom, top)
+	yh := s.scale(d.Values[1], mindata, maxdata, bottom, top)
+	yl := s.scale(d.Values[2], mindata, maxdata, bottom, top)
+	yc := s.scale(d.Values[3], mindata, maxdata, bottom, top)
+	color := s.updown(d)
+	deck.Line(x, yl, x, yh, lw, color)
+	if s.Flags.ShowOHLC {
+		deck.Line(x-(w/2), yo, x, yo, lw, color)
+		deck.Line(x, yc, x+(w/2), yc, lw, color)
+		return
+	}
+	bh := math.Abs(yo - yc)
+	if bh < lw {
+		bh = lw
+	}
+	deck.Rect(x, (yo+yc)/2, w, bh, color)
+}
+
+// stackpoly makes the outline of a stacked area: along the upper
+// boundary, then back along the lower boundary
+func stackpoly(x, upper, lower []float64) ([]float64, []float64) {
+	n := len(x)
+	px := make([]float64, n*2)
+	py := make([]float64, n*2)
+	for i := 0; i < n; i++ {
+		px[i], py[i] = x[i], upper[i]
+		px[(n*2)-1-i], py[(n*2)-1-i] = x[i], lower[i]
+	}
+	return px, py
+}
+
+// seriescolors returns the colors for n series: the space-separated list
+// of series colors, or the default palette. A single series uses the data color.
+func (s *Settings) seriescolors(n int) []string {
+	if n == 1 {
+		return []string{s.Attributes.DataColor}
+	}
+	palette := strings.Fields(s.Attributes.SeriesColors)
+	if len(palette) == 0 {
+		palette = seriespalette
+	}
+	colors := make([]string, n)
+	for i := 0; i < n; i++ {
+		colors[i] = palette[i%len(palette)]
+	}
+	return colors
+}
+
+// parsecolor converts a deck color (name, #rgb, #rrggbb, rgb(r,g,b) or hsv(h,s,v))
+// to an opaque color, for renderers and charts that compute with colors.
+// Unknown colors are black. For gradient specifications (color1/color2/percent)
+// the first color is used.
+func parsecolor(s string) color.NRGBA {
+	c := color.NRGBA{A: 255}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if i := strings.Index(s, "/"); i > 0 {
+		s = s[:i]
+	}
+	switch {
+	case strings.HasPrefix(s, "#"):
+		h := s[1:]
+		if len(h) == 3 {
+			h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+		}
+		v, err := strconv.ParseUint(h, 16, 32)
+		if err == nil && len(h) == 6 {
+			c = color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+		}
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		v := colorargs(s[4 : len(s)-1])
+		c = color.NRGBA{uint8(v[0]), uint8(v[1]), uint8(v[2]), 255}
+	case strings.HasPrefix(s, "hsv(") && strings.HasSuffix(s, ")"):
+		v := colorargs(s[4 : len(s)-1])
+		r, g, b := hsv(v[0], v[1]/100, v[2]/100)
+		c = color.NRGBA{uint8(r * 255), uint8(g * 255), uint8(b * 255), 255}
+	default:
+		if n, ok := colornames.Map[s]; ok {
+			c = color.NRGBA{n.R, n.G, n.B, 255}
+		}
+	}
+	return c
+}
+
+// colorargs parses three comma-separated color components, clamped to 0-255
+func colorargs(s string) [3]float64 {
+	var v [3]float64
+	for i, f := range strings.SplitN(s, ",", 3) {
+		n, _ := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		v[i] = math.Max(0, math.Min(n, 255))
+	}
+	return v
+}
+
+// hsv converts hue (degrees), saturation and value (0-1) to r, g, b (0-1)
+func hsv(h, s, v float64) (float64, float64, float64) {
+	h = math.Mod(h, 360) / 60
+	s, v = math.Min(s, 1), math.Min(v, 1)
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return r + m, g + m, b + m
+}
+
+// seriesnames returns the legend names for n series, either from the
+// comma-separated series names, the CSV columns, or numbered
+func (s *Settings) seriesnames(n int) []string {
+	var given []string
+	switch {
+	case len(s.Attributes.SeriesNames) > 0:
+		given = strings.Split(s.Attributes.SeriesNames, ",")
+	case s.Flags.ReadCSV && len(s.Attributes.CSVCols) > 0:
+		given = strings.Split(s.Attributes.CSVCols, ",")[1:]
+	}
+	names := make([]string, n)
+	for i := 0; i < n; i++ {
+		if i < len(given) {
+			names[i] = xmlesc(strings.TrimSpace(given[i]))
+		} else {
+			names[i] = fmt.Sprintf("Series %d", i+1)
+		}
+	}
+	return names
+}
+
+// serieslegend makes a horizontal legend for multi-series charts beginning at (x,y),
+// using line segments for line charts and squares otherwise
+func (s *Settings) serieslegend(deck Renderer, n int, colors []string, x, y float64) {
+	ts := s.Measures.TextSize
+	lsize := ts * 0.75
+	names := s.seriesnames(n)
+	for i := 0; i < n; i++ {
+		if s.Flags.ShowLine && !s.Flags.ShowBar && !s.Flags.ShowVolume {
+			deck.Line(x, y, x+ts, y, s.Measures.LineWidth*2, colors[i])
+		} else {
+			deck.Square(x+ts/2, y, ts*0.7, colors[i])
+		}
+		deck.Text(x+ts*1.5, y-lsize/3, names[i], "sans", lsize, s.Attributes.LabelColor)
+		x += ts*3 + float64(len(names[i]))*lsize*0.6
+	}
 }
 
 // mean computes the arithmetic mean of a set of data
@@ -1572,74 +2975,119 @@ func mean(x []float64) float64 {
 	return sum / float64(n)
 }
 
-// slope computes the slope (m, b) of a set of x, y points
+// slope computes the slope (m, b) of a set of x, y points.
+// With no spread in x, the line is level at the mean of y.
 func slope(x, y []float64) (float64, float64) {
 	n := len(x) // assume x and y have the same length
-	xy := make([]float64, n)
-	for i := 0; i < n; i++ {
-		xy[i] = x[i] * y[i]
-	}
-	sqx := make([]float64, n)
-	for i := 0; i < n; i++ {
-		sqx[i] = x[i] * x[i]
+	if n == 0 {
+		return 0, 0
 	}
-	meanxy := mean(xy)
 	meanx := mean(x)
 	meany := mean(y)
-	meanxsq := mean(sqx)
-
-	rise := (meanxy - (meanx * meany))
-	run := (meanxsq - (meanx * meanx))
+	rise, run := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		rise += (x[i] - meanx) * (y[i] - meany)
+		run += (x[i] - meanx) * (x[i] - meanx)
+	}
+	if run == 0 {
+		return 0, meany
+	}
 	m := rise / run
 	b := meany - (m * meanx)
 	return m, b
 }
 
-// rline makes a regression line
-func (measures *Measures) rline(deck *deckgen.DeckGen, x, y []float64, mindata, maxdata float64, color string) {
-	top := measures.Top
-	left := measures.Left
-	if left < 0 {
-		left = 10.0
+// rline makes a regression line of y on x, across the range of x,
+// placing points on the chart with point; on a logarithmic scale the line is
+// drawn as a series of segments. The 95% confidence band of the line,
+// within the values vmin to vmax, is shown if specified.
+// The fit is returned; data that cannot be fit is reported as a warning.
+func (s *Settings) rline(deck Renderer, x, y []float64, vmin, vmax float64, point func(x, y float64) (float64, float64), color string) (regression, bool) {
+	reg, err := regress(x, y)
+	if err != nil {
+		s.Warnings = append(s.Warnings, err)
+		return reg, false
+	}
+	lw := s.Measures.LineWidth
+	x1, x2 := minmax(x, largest, smallest)
+	if s.Flags.ShowRegressionBand && reg.n > 2 {
+		const segments = 50
+		var bx, by []float64
+		edge := func(i int, sign float64) {
+			xv := x1 + (x2-x1)*float64(i)/segments
+			v := reg.at(xv) + sign*reg.interval(xv)
+			px, py := point(xv, math.Max(vmin, math.Min(vmax, v)))
+			bx, by = append(bx, px), append(by, py)
+		}
+		for i := 0; i <= segments; i++ {
+			edge(i, 1)
+		}
+		for i := segments; i >= 0; i-- {
+			edge(i, -1)
+		}
+		deck.Polygon(bx, by, color, 20)
+	}
+	nseg := 1
+	if s.Flags.LogScale {
+		nseg = 50
 	}
-	bottom := measures.Bottom
-	right := measures.Right
-	lw := measures.LineWidth
-	m, b := slope(x, y)
-	dl := len(x) - 1
-	l := float64(dl)
-	x1 := x[0]
-	x2 := x[dl]
-	y1 := m*x1 + b
-	y2 := m*x2 + b
-	rx1 := vmap(x1, 0, l, left, right)
-	rx2 := vmap(x2, 0, l, left, right)
-	ry1 := vmap(y1, mindata, maxdata, bottom, top)
-	ry2 := vmap(y2, mindata, maxdata, bottom, top)
-	deck.Line(rx1, ry1, rx2, ry2, lw, color)
+	step := (x2 - x1) / float64(nseg)
+	for i := 0; i < nseg; i++ {
+		xa := x1 + float64(i)*step
+		xb := xa + step
+		rx1, ry1 := point(xa, reg.at(xa))
+		rx2, ry2 := point(xb, reg.at(xb))
+		deck.Line(rx1, ry1, rx2, ry2, lw, color)
+	}
+	return reg, true
 }
 
 // GenerateChart makes charts according to the orientation:
 // horizontal bar or line, bar, dot, or donut volume charts
-func (s *Settings) GenerateChart(deck *deckgen.DeckGen, r io.ReadCloser) {
+func (s *Settings) GenerateChart(deck Renderer, r io.ReadCloser) error {
+	ds, err := s.dataset(r)
+	if err != nil {
+		return err
+	}
+	return s.render(deck, ds)
+}
+
+// Render makes charts from data in memory, according to the chart type,
+// as GenerateChart does for data read from input
+func (s *Settings) Render(deck Renderer, data []ChartData, title string) error {
+	ds, err := s.transform(NewDataset(data, title))
+	if err != nil {
+		return err
+	}
+	return s.render(deck, ds)
+}
+
+// render dispatches a dataset to the chart type
+func (s *Settings) render(deck Renderer, ds Dataset) error {
 	f := s.Flags
 	switch {
+	case f.ShowHeatmap:
+		return s.heatmap(deck, ds)
+	case f.ShowCalendar:
+		return s.calendar(deck, ds)
+	case f.ShowBox, f.ShowViolin:
+		return s.boxchart(deck, ds)
 	case f.ShowHBar:
-		s.Hchart(deck, r)
+		return s.hchart(deck, ds)
 	case f.ShowWBar:
-		s.Wbchart(deck, r)
-	case f.ShowDonut, f.ShowPMap, f.ShowPGrid, f.ShowRadial, f.ShowLego, f.ShowFan, f.ShowBowtie:
-		s.Pchart(deck, r)
+		return s.wbchart(deck, ds)
+	case f.ShowDonut, f.ShowPMap, f.ShowPGrid, f.ShowRadial, f.ShowLego, f.ShowFan, f.ShowBowtie, f.ShowTreemap:
+		return s.pchart(deck, ds)
 	case f.ShowSlope:
-		s.Slopechart(deck, r)
+		return s.slopechart(deck, ds)
 	default:
-		s.Vchart(deck, r)
+		return s.vchart(deck, ds)
 	}
 }
 
 // Write performs chart I/O
-func (s *Settings) Write(w io.Writer, r io.ReadCloser) {
-	s.GenerateChart(deckgen.NewSlides(w, 0, 0), r)
+func (s *Settings) Write(w io.Writer, r io.ReadCloser) error {
+	return s.GenerateChart(deckgen.NewSlides(w, 0, 0), r)
 }
 
 // NewFullChart initializes the settings required to make a chart
@@ -1652,7 +3100,9 @@ func NewFullChart(chartType string, top, bottom, left, right float64) Settings {
 
 // NewChart initializes the settings required to make a chart
 // chartType may be one of: "line", "slope", "bar", "wbar", "hbar",
-// "volume, "scatter", "donut", "pmap", "pgrid", "lego", "radial", "bowtie", "fan"
+// "volume, "scatter", "donut", "pmap", "pgrid", "lego", "radial", "bowtie", "fan",
+// "stackedbar", "stackedarea", "stacked100", "candle", "ohlc", "xy", "bubble",
+// "hist", "box", "violin", "waterfall", "heatmap", "calendar", "treemap"
 func NewChart(chartType string, top, bottom, left, right float64) Settings {
 	var s Settings
 
@@ -1671,6 +3121,8 @@ func NewChart(chartType string, top, bottom, left, right float64) Settings {
 		s.Flags.ShowFan = true
 	case "pmap":
 		s.Flags.ShowPMap = true
+	case "treemap":
+		s.Flags.ShowTreemap = true
 	case "pgrid":
 		s.Flags.ShowPGrid = true
 	case "lego":
@@ -1681,10 +3133,39 @@ func NewChart(chartType string, top, bottom, left, right float64) Settings {
 		s.Flags.ShowLine = true
 	case "scatter":
 		s.Flags.ShowScatter = true
+	case "xy", "bubble":
+		s.Flags.ShowXY = true
 	case "volume", "area":
 		s.Flags.ShowVolume = true
 	case "slope":
 		s.Flags.ShowSlope = true
+	case "stackedbar":
+		s.Flags.ShowBar = true
+		s.Flags.ShowStack = true
+	case "stackedarea":
+		s.Flags.ShowVolume = true
+		s.Flags.ShowStack = true
+	case "stacked100":
+		s.Flags.ShowBar = true
+		s.Flags.StackPercent = true
+	case "hist", "histogram":
+		s.Flags.ShowBar = true
+		s.Flags.ShowHistogram = true
+	case "heatmap":
+		s.Flags.ShowHeatmap = true
+	case "calendar":
+		s.Flags.ShowCalendar = true
+	case "waterfall":
+		s.Flags.ShowBar = true
+		s.Flags.ShowWaterfall = true
+	case "box", "boxplot":
+		s.Flags.ShowBox = true
+	case "violin":
+		s.Flags.ShowViolin = true
+	case "candle":
+		s.Flags.ShowCandle = true
+	case "ohlc":
+		s.Flags.ShowOHLC = true
 	}
 	if left <= 0 {
 		left = 10
@@ -1711,6 +3192,9 @@ func NewChart(chartType string, top, bottom, left, right float64) Settings {
 	s.Attributes.BackgroundColor = "white"
 	s.Attributes.DataColor = "lightsteelblue"
 	s.Attributes.LabelColor = "rgb(75,75,75)"
+	s.Attributes.UpColor = "rgb(0,128,0)"
+	s.Attributes.DownColor = "rgb(200,0,0)"
+	s.Attributes.LegendPosition = "top"
 
 	return s
 }
@@ -0,0 +1,452 @@
+package dchart
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeSeries(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		xpos   []float64
+	}{
+		{"empty", nil, []float64{}},
+		{"single", []string{"2024-03-05"}, []float64{50}},
+		{"two", []string{"2024-03-05", "2024-03-07"}, []float64{10, 90}},
+	}
+	for _, test := range tests {
+		var data []ChartData
+		for i, l := range test.labels {
+			data = append(data, NewChartData(l, "", float64(i+1)))
+		}
+		s := NewChart("line", 0, 0, 0, 0)
+		s.Flags.ShowTimeAxis = true
+		times, err := s.parsetimes(data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		xpos, gap := s.xpositions(data, times, 10, 90)
+		if len(xpos) != len(test.xpos) {
+			t.Fatalf("%s: %d positions, want %d", test.name, len(xpos), len(test.xpos))
+		}
+		for i := range xpos {
+			if xpos[i] != test.xpos[i] {
+				t.Errorf("%s: position %d is %v, want %v", test.name, i, xpos[i], test.xpos[i])
+			}
+		}
+		if !(gap > 0) {
+			t.Errorf("%s: gap %v is not positive", test.name, gap)
+		}
+
+		for _, chart := range []string{"bar", "line", "scatter", "candle"} {
+			s := NewChart(chart, 0, 0, 0, 0)
+			s.Flags.ShowTimeAxis = true
+			s.Flags.ShowAxis = true
+			s.Attributes.References = "label:2024-03-06"
+			var buf bytes.Buffer
+			deck := NewDecksh(&buf)
+			deck.StartDeck()
+			if err := s.Render(deck, data, ""); err != nil {
+				t.Errorf("%s %s: %v", test.name, chart, err)
+			}
+			deck.EndDeck()
+			if out := buf.String(); strings.Contains(out, "NaN") || strings.Contains(out, "Inf") {
+				t.Errorf("%s %s: non-finite coordinates:\n%s", test.name, chart, out)
+			}
+		}
+	}
+}
+
+func TestReadData(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		csv      bool
+		cols     string
+		strict   bool
+		values   [][]float64
+		notes    []string
+		warnings []string
+		err      string
+	}{
+		{
+			name:   "tsv",
+			input:  "# title\na\t1\nb\t2\tnote\n",
+			values: [][]float64{{1}, {2}},
+			notes:  []string{"", "note"},
+		},
+		{
+			name:     "tsv bad numbers",
+			input:    "a\tx\nb\tNaN\nc\t3\tInf\nd\t4\n",
+			values:   [][]float64{{0}, {0}, {3, 0}, {4}},
+			notes:    []string{"", "", "", ""},
+			warnings: []string{`line 1, column 2: "x": not a finite number`, `line 2, column 2: "NaN": not a finite number`, `line 3, column 3: "Inf": not a finite number`},
+		},
+		{
+			name:   "tsv strict",
+			input:  "a\t1\nb\t+Inf\nc\tx\n",
+			strict: true,
+			err:    `line 2, column 2: "+Inf": not a finite number`,
+		},
+		{
+			name:   "csv",
+			input:  "a,1,x\nb,2,3\n",
+			csv:    true,
+			values: [][]float64{{1}, {2}},
+			notes:  []string{"x", "3"},
+		},
+		{
+			name:   "csv columns",
+			input:  "name,a,b,c\nx,1,2,3\ny,4,5,6\n",
+			csv:    true,
+			cols:   "name,c,a",
+			values: [][]float64{{3, 1}, {6, 4}},
+			notes:  []string{"", ""},
+		},
+		{
+			name:     "csv missing column",
+			input:    "name,a,b\nx,1,2\ny,4\n",
+			csv:      true,
+			cols:     "name,a,b",
+			values:   [][]float64{{1, 2}, {4, 0}},
+			notes:    []string{"", ""},
+			warnings: []string{"line 3, column b: missing"},
+		},
+		{
+			name:   "csv missing column strict",
+			input:  "name,a,b\nx,1,2\ny,4\n",
+			csv:    true,
+			cols:   "name,a,b",
+			strict: true,
+			err:    "line 3, column b: missing",
+		},
+		{
+			name:     "csv header mismatch",
+			input:    "name,a,b\nx,1,2\n",
+			csv:      true,
+			cols:     "name,a,z",
+			values:   [][]float64{{1, 2}},
+			notes:    []string{""},
+			warnings: []string{"line 1, column z: not in the header"},
+		},
+		{
+			name:   "csv header mismatch strict",
+			input:  "name,a,b\nx,1,2\n",
+			csv:    true,
+			cols:   "label,a",
+			strict: true,
+			err:    "line 1, column label: not in the header",
+		},
+		{
+			name:   "csv bad number strict",
+			input:  "name,a\nx,1\ny,NaN\n",
+			csv:    true,
+			cols:   "name,a",
+			strict: true,
+			err:    `line 3, column a: "NaN": not a finite number`,
+		},
+	}
+	for _, test := range tests {
+		ds, err := ReadData(strings.NewReader(test.input), test.csv, test.cols, test.strict)
+		if len(test.err) > 0 {
+			var perr *ParseError
+			if !errors.As(err, &perr) || err.Error() != test.err {
+				t.Errorf("%s: error %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(ds.Data) != len(test.values) {
+			t.Errorf("%s: %d rows, want %d", test.name, len(ds.Data), len(test.values))
+			continue
+		}
+		for i, d := range ds.Data {
+			if !equalfloats(d.Values, test.values[i]) || d.Value != d.Values[0] {
+				t.Errorf("%s: row %d values %v, want %v", test.name, i, d.Values, test.values[i])
+			}
+			if d.Note != test.notes[i] {
+				t.Errorf("%s: row %d note %q, want %q", test.name, i, d.Note, test.notes[i])
+			}
+		}
+		if len(ds.Warnings) != len(test.warnings) {
+			t.Errorf("%s: warnings %v, want %v", test.name, ds.Warnings, test.warnings)
+			continue
+		}
+		for i, w := range ds.Warnings {
+			if w.Error() != test.warnings[i] || !errors.As(w, new(*ParseError)) {
+				t.Errorf("%s: warning %v, want %s", test.name, w, test.warnings[i])
+			}
+		}
+	}
+}
+
+// equalfloats reports whether two slices have the same values, to within rounding
+func equalfloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParsecolor(t *testing.T) {
+	tests := []struct {
+		s       string
+		r, g, b uint8
+	}{
+		{"red", 255, 0, 0},
+		{" SteelBlue ", 70, 130, 180},
+		{"#0f8", 0, 255, 136},
+		{"#102030", 16, 32, 48},
+		{"rgb(10, 300, -5)", 10, 255, 0},
+		{"hsv(120,100,100)", 0, 255, 0},
+		{"blue/white/50", 0, 0, 255},
+		{"nosuchcolor", 0, 0, 0},
+		{"#12", 0, 0, 0},
+	}
+	for _, test := range tests {
+		c := parsecolor(test.s)
+		if c.R != test.r || c.G != test.g || c.B != test.b || c.A != 255 {
+			t.Errorf("%q: %v, want %d,%d,%d", test.s, c, test.r, test.g, test.b)
+		}
+	}
+}
+
+func TestTSVNote(t *testing.T) {
+	input := "a\t5\t10\nb\t3\t4\t5\nc\t2\tnote\n"
+	tests := []struct {
+		note3  bool
+		values [][]float64
+		notes  []string
+	}{
+		{false, [][]float64{{5, 10}, {3, 4, 5}, {2}}, []string{"", "", "note"}},
+		{true, [][]float64{{5}, {3, 4, 5}, {2}}, []string{"10", "", "note"}},
+	}
+	for _, test := range tests {
+		ds, err := readTSV(strings.NewReader(input), true, test.note3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, d := range ds.Data {
+			if !equalfloats(d.Values, test.values[i]) || d.Note != test.notes[i] {
+				t.Errorf("note3 %v: row %d is %v %q, want %v %q", test.note3, i, d.Values, d.Note, test.values[i], test.notes[i])
+			}
+		}
+	}
+}
+
+func TestNicenum(t *testing.T) {
+	tests := []struct {
+		x     float64
+		round bool
+		want  float64
+	}{
+		{1, false, 1},
+		{1.5, false, 2},
+		{3, false, 5},
+		{7, false, 10},
+		{0.03, false, 0.05},
+		{1200, false, 2000},
+		{1.4, true, 1},
+		{2.9, true, 2},
+		{6, true, 5},
+		{8, true, 10},
+		{250, true, 200},
+		{45, true, 50},
+	}
+	for _, test := range tests {
+		if got := nicenum(test.x, test.round); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("nicenum(%v, %v) = %v, want %v", test.x, test.round, got, test.want)
+		}
+	}
+}
+
+func TestCyrange(t *testing.T) {
+	tests := []struct {
+		min, max        float64
+		n               int
+		amin, amax, stp float64
+	}{
+		{0, 100, 5, 0, 100, 20},
+		{-3, 7, 6, -4, 8, 2},
+		{5, 5, 3, 2, 8, 2},
+		{0, 0, 3, 0, 1, 0.5},
+		{1, 0, 5, 0, 1, 0.2},
+		{0, math.Inf(1), 5, 0, 1, 0.2},
+		{math.NaN(), 1, 5, 0, 1, 0.2},
+		{0, 100, 1, 0, 100, 100},
+	}
+	for _, test := range tests {
+		amin, amax, step := cyrange(test.min, test.max, test.n)
+		if !equalfloats([]float64{amin, amax, step}, []float64{test.amin, test.amax, test.stp}) {
+			t.Errorf("cyrange(%v, %v, %d) = %v, %v, %v, want %v, %v, %v",
+				test.min, test.max, test.n, amin, amax, step, test.amin, test.amax, test.stp)
+		}
+	}
+}
+
+func TestAxisvalues(t *testing.T) {
+	tests := []struct {
+		axismin, axismax, step, dmin, dmax float64
+		want                               []float64
+	}{
+		{0, 100, 20, 0, 100, []float64{0, 20, 40, 60, 80, 100}},
+		{-4, 8, 2, -3, 7, []float64{-2, 0, 2, 4, 6}},
+		{0, 1, 0.1, 0, 1, []float64{0, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}},
+		{0, 10, 5, 10, 0, []float64{0, 5, 10}},
+		{0, 10, 0, 0, 10, nil},
+		{0, 10, -1, 0, 10, nil},
+		{0, 10, math.NaN(), 0, 10, nil},
+		{0, 10, math.Inf(1), 0, 10, nil},
+		{0, math.Inf(1), 1, 0, 10, nil},
+	}
+	for _, test := range tests {
+		got := axisvalues(test.axismin, test.axismax, test.step, test.dmin, test.dmax)
+		if !equalfloats(got, test.want) {
+			t.Errorf("axisvalues(%v, %v, %v, %v, %v) = %v, want %v",
+				test.axismin, test.axismax, test.step, test.dmin, test.dmax, got, test.want)
+		}
+	}
+}
+
+func TestAxislabel(t *testing.T) {
+	tests := []struct {
+		v, step float64
+		datafmt string
+		want    string
+	}{
+		{5, 1, "", "5"},
+		{100, 20, Defaultfmt, "100"},
+		{0.25, 0.05, "", "0.25"},
+		{1.5, 0.5, "", "1.5"},
+		{-1e-12, 0.5, "", "0.0"},
+		{3.14159, 1, "%.2f", "3.14"},
+	}
+	for _, test := range tests {
+		s := NewChart("bar", 0, 0, 0, 0)
+		s.Attributes.DataFmt = test.datafmt
+		if got := s.axislabel(test.v, test.step); got != test.want {
+			t.Errorf("axislabel(%v, %v) with %q = %q, want %q", test.v, test.step, test.datafmt, got, test.want)
+		}
+	}
+}
+
+func TestLogrange(t *testing.T) {
+	tests := []struct {
+		name             string
+		values           []float64
+		base             float64
+		usermin, usermax float64
+		strict           bool
+		dmin, dmax       float64
+		warnings         int
+		err              bool
+	}{
+		{name: "decades", values: []float64{1, 2, 50}, dmin: 1, dmax: 100},
+		{name: "fractions", values: []float64{0.3, 7}, dmin: 0.1, dmax: 10},
+		{name: "constant", values: []float64{5, 5}, dmin: 1, dmax: 10},
+		{name: "power", values: []float64{10}, dmin: 10, dmax: 100},
+		{name: "base 2", values: []float64{3, 9}, base: 2, dmin: 2, dmax: 16},
+		{name: "user range", values: []float64{1, 50}, usermin: 0.5, usermax: 1000, dmin: 0.5, dmax: 1000},
+		{name: "clipped", values: []float64{-1, 0, 4}, dmin: 1, dmax: 10, warnings: 2},
+		{name: "strict", values: []float64{-1, 4}, strict: true, err: true},
+		{name: "no positive values", values: []float64{0, -2}, err: true},
+	}
+	for _, test := range tests {
+		s := NewChart("line", 0, 0, 0, 0)
+		s.Flags.LogScale = true
+		s.Flags.StrictData = test.strict
+		s.Measures.LogBase = test.base
+		s.Measures.UserMin, s.Measures.UserMax = test.usermin, test.usermax
+		data := []ChartData{NewChartData("a", "", test.values...)}
+		vmin, vmax := test.values[0], test.values[0]
+		for _, v := range test.values {
+			vmin, vmax = math.Min(vmin, v), math.Max(vmax, v)
+		}
+		dmin, dmax, err := s.logrange(data, vmin, vmax)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !equalfloats([]float64{dmin, dmax}, []float64{test.dmin, test.dmax}) {
+			t.Errorf("%s: range %v to %v, want %v to %v", test.name, dmin, dmax, test.dmin, test.dmax)
+		}
+		if len(s.Warnings) != test.warnings {
+			t.Errorf("%s: warnings %v, want %d", test.name, s.Warnings, test.warnings)
+		}
+	}
+}
+
+func TestTimeticks(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name       string
+		tmin, tmax string
+		n          int
+		ticks      []string
+		labels     []string
+	}{
+		{
+			name: "hours", tmin: "2024-03-05 00:00", tmax: "2024-03-07 00:00", n: 5,
+			ticks:  []string{"2024-03-05 00:00", "2024-03-05 12:00", "2024-03-06 00:00", "2024-03-06 12:00", "2024-03-07 00:00"},
+			labels: []string{`00:00\nMar 5`, "12:00", `00:00\nMar 6`, "12:00", `00:00\nMar 7`},
+		},
+		{
+			name: "weeks", tmin: "2024-03-06 00:00", tmax: "2024-04-10 00:00", n: 8,
+			ticks:  []string{"2024-03-11 00:00", "2024-03-18 00:00", "2024-03-25 00:00", "2024-04-01 00:00", "2024-04-08 00:00"},
+			labels: []string{`Mar 11\n2024`, "Mar 18", "Mar 25", "Apr 1", "Apr 8"},
+		},
+		{
+			name: "quarters", tmin: "2024-01-15 00:00", tmax: "2025-01-20 00:00", n: 6,
+			ticks:  []string{"2024-04-01 00:00", "2024-07-01 00:00", "2024-10-01 00:00", "2025-01-01 00:00"},
+			labels: []string{`Q2\n2024`, "Q3", "Q4", `Q1\n2025`},
+		},
+		{
+			name: "decades", tmin: "1990-06-01 00:00", tmax: "2024-06-01 00:00", n: 5,
+			ticks:  []string{"2000-01-01 00:00", "2010-01-01 00:00", "2020-01-01 00:00"},
+			labels: []string{"2000", "2010", "2020"},
+		},
+	}
+	for _, test := range tests {
+		ticks, step := timeticks(date(test.tmin), date(test.tmax), test.n)
+		if len(ticks) != len(test.ticks) {
+			t.Errorf("%s: ticks %v, want %v", test.name, ticks, test.ticks)
+			continue
+		}
+		prev := ""
+		for i, tick := range ticks {
+			if !tick.Equal(date(test.ticks[i])) {
+				t.Errorf("%s: tick %d is %v, want %s", test.name, i, tick, test.ticks[i])
+			}
+			var label string
+			label, prev = step.format(tick, prev)
+			if label != test.labels[i] {
+				t.Errorf("%s: label %d is %q, want %q", test.name, i, label, test.labels[i])
+			}
+		}
+	}
+}
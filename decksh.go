@@ -0,0 +1,164 @@
+package dchart
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Decksh renders charts as decksh commands, suitable for including
+// or importing into decksh source. Deck and slide commands are only
+// written for full decks.
+type Decksh struct {
+	dest   io.Writer
+	indent string
+}
+
+// NewDecksh initializes the decksh renderer, writing to w
+func NewDecksh(w io.Writer) *Decksh {
+	return &Decksh{dest: w}
+}
+
+// dshnum formats a number for decksh, without trailing zeros
+func dshnum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// dshstring quotes a string for decksh; chart text is unescaped
+// since decksh escapes text, and double quotes become single quotes
+func dshstring(s string) string {
+	return `"` + strings.ReplaceAll(html.UnescapeString(s), `"`, `'`) + `"`
+}
+
+// command writes a decksh command with its arguments
+func (p *Decksh) command(name string, args ...string) {
+	fmt.Fprintf(p.dest, "%s%s %s\n", p.indent, name, strings.Join(args, " "))
+}
+
+// nums formats numbers as decksh arguments
+func nums(v ...float64) []string {
+	s := make([]string, len(v))
+	for i, f := range v {
+		s[i] = dshnum(f)
+	}
+	return s
+}
+
+// colorop formats the color and the optional opacity
+func colorop(color string, opacity []float64) []string {
+	s := []string{dshstring(color)}
+	if len(opacity) > 0 {
+		s = append(s, dshnum(opacity[0]))
+	}
+	return s
+}
+
+// StartDeck begins the deck
+func (p *Decksh) StartDeck() {
+	fmt.Fprintln(p.dest, "deck")
+	p.indent = "\t"
+}
+
+// EndDeck ends the deck
+func (p *Decksh) EndDeck() {
+	p.indent = ""
+	fmt.Fprintln(p.dest, "edeck")
+}
+
+// StartSlide begins a slide, with optional background and foreground colors
+func (p *Decksh) StartSlide(colors ...string) {
+	args := make([]string, len(colors))
+	for i, c := range colors {
+		args[i] = dshstring(c)
+	}
+	fmt.Fprintln(p.dest, strings.TrimRight(p.indent+"slide "+strings.Join(args, " "), " "))
+	p.indent += "\t"
+}
+
+// EndSlide ends a slide
+func (p *Decksh) EndSlide() {
+	if len(p.indent) > 0 {
+		p.indent = p.indent[1:]
+	}
+	fmt.Fprintln(p.dest, p.indent+"eslide")
+}
+
+// text writes a text command
+func (p *Decksh) text(name string, x, y float64, s, font string, size float64, color string, opacity []float64) {
+	args := append([]string{dshstring(s)}, nums(x, y, size)...)
+	args = append(args, dshstring(font))
+	p.command(name, append(args, colorop(color, opacity)...)...)
+}
+
+// Text places left-aligned text at (x,y)
+func (p *Decksh) Text(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text("text", x, y, s, font, size, color, opacity)
+}
+
+// TextMid places centered text at (x,y)
+func (p *Decksh) TextMid(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text("ctext", x, y, s, font, size, color, opacity)
+}
+
+// TextEnd places right-aligned text at (x,y)
+func (p *Decksh) TextEnd(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text("etext", x, y, s, font, size, color, opacity)
+}
+
+// TextRotate places text at (x,y), rotated counter-clockwise by degrees
+func (p *Decksh) TextRotate(x, y float64, s, link, font string, rotation, size float64, color string, opacity ...float64) {
+	args := append([]string{dshstring(s)}, nums(x, y, rotation, size)...)
+	args = append(args, dshstring(font), dshstring(color))
+	op := 100.0
+	if len(opacity) > 0 {
+		op = opacity[0]
+	}
+	args = append(args, dshnum(op))
+	if len(link) > 0 {
+		args = append(args, dshstring(link))
+	}
+	p.command("rtext", args...)
+}
+
+// Line makes a line from (x1,y1) to (x2,y2) with thickness size
+func (p *Decksh) Line(x1, y1, x2, y2, size float64, color string, opacity ...float64) {
+	p.command("line", append(nums(x1, y1, x2, y2, size), colorop(color, opacity)...)...)
+}
+
+// Circle makes a circle centered at (x,y) with diameter w
+func (p *Decksh) Circle(x, y, w float64, color string, opacity ...float64) {
+	p.command("circle", append(nums(x, y, w), colorop(color, opacity)...)...)
+}
+
+// Square makes a square centered at (x,y) with width w
+func (p *Decksh) Square(x, y, w float64, color string, opacity ...float64) {
+	p.command("square", append(nums(x, y, w), colorop(color, opacity)...)...)
+}
+
+// Rect makes a rectangle centered at (x,y) with dimensions (w,h)
+func (p *Decksh) Rect(x, y, w, h float64, color string, opacity ...float64) {
+	p.command("rect", append(nums(x, y, w, h), colorop(color, opacity)...)...)
+}
+
+// Arc makes an arc centered at (x,y) with dimensions (w,h) and thickness size,
+// between the angles a1 and a2 (degrees, counter-clockwise)
+func (p *Decksh) Arc(x, y, w, h, size, a1, a2 float64, color string, opacity ...float64) {
+	p.command("arc", append(nums(x, y, w, h, a1, a2, size), colorop(color, opacity)...)...)
+}
+
+// Polygon makes a filled polygon with vertices in x and y
+func (p *Decksh) Polygon(x, y []float64, color string, opacity ...float64) {
+	if len(x) < 3 || len(x) != len(y) {
+		return
+	}
+	xs := `"` + strings.Join(nums(x...), " ") + `"`
+	ys := `"` + strings.Join(nums(y...), " ") + `"`
+	p.command("polygon", append([]string{xs, ys}, colorop(color, opacity)...)...)
+}
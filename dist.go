@@ -0,0 +1,278 @@
+package dchart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// distgroup is the sorted values of the data items sharing a label
+type distgroup struct {
+	label  string
+	values []float64
+}
+
+// distgroups groups the values of data by label, in order of first appearance
+func distgroups(data []ChartData) []distgroup {
+	var groups []distgroup
+	index := map[string]int{}
+	for _, d := range data {
+		i, ok := index[d.Label]
+		if !ok {
+			i = len(groups)
+			index[d.Label] = i
+			groups = append(groups, distgroup{label: d.Label})
+		}
+		groups[i].values = append(groups[i].values, d.Values...)
+	}
+	for _, g := range groups {
+		sort.Float64s(g.values)
+	}
+	return groups
+}
+
+// boxstats summarizes a distribution: the quartiles, the ends of the whiskers
+// (the most extreme values within 1.5 IQR of the quartiles), and the outliers beyond
+type boxstats struct {
+	q1, median, q3 float64
+	lo, hi         float64
+	outliers       []float64
+}
+
+// newboxstats computes the summary of sorted values
+func newboxstats(sorted []float64) boxstats {
+	b := boxstats{
+		q1:     quantile(sorted, 0.25),
+		median: quantile(sorted, 0.5),
+		q3:     quantile(sorted, 0.75),
+	}
+	fence := 1.5 * (b.q3 - b.q1)
+	b.lo, b.hi = b.q1, b.q3
+	for _, v := range sorted {
+		switch {
+		case v < b.q1-fence || v > b.q3+fence:
+			b.outliers = append(b.outliers, v)
+		case v < b.lo:
+			b.lo = v
+		case v > b.hi:
+			b.hi = v
+		}
+	}
+	return b
+}
+
+// kdebandwidth returns the bandwidth of a gaussian kernel density estimate
+// of sorted values, using Silverman's rule of thumb
+func kdebandwidth(sorted []float64) float64 {
+	a := stddev(sorted)
+	if iqr := (quantile(sorted, 0.75) - quantile(sorted, 0.25)) / 1.34; iqr > 0 && iqr < a {
+		a = iqr
+	}
+	return 0.9 * a * math.Pow(float64(len(sorted)), -0.2)
+}
+
+// kde returns the gaussian kernel density estimate of values at x, with bandwidth h
+func kde(values []float64, h, x float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		u := (x - v) / h
+		sum += math.Exp(-u * u / 2)
+	}
+	return sum / (float64(len(values)) * h * math.Sqrt(2*math.Pi))
+}
+
+// Boxchart makes box or violin plots using input from a Reader
+func (s *Settings) Boxchart(deck Renderer, r io.ReadCloser) error {
+	ds, err := s.dataset(r)
+	if err != nil {
+		return err
+	}
+	return s.boxchart(deck, ds)
+}
+
+// boxchart makes a box plot (or a violin plot, adding the outline of the
+// kernel density) of the values of each label. Boxes are vertical, or horizontal
+// if ShowHBar is set; the value scale is the range of the data,
+// linear or (vertically) logarithmic.
+func (s *Settings) boxchart(deck Renderer, ds Dataset) error {
+	groups := distgroups(ds.Data)
+	if len(groups) == 0 {
+		return errors.New("box and violin plots need data")
+	}
+	f := s.Flags
+	horizontal := f.ShowHBar
+	mindata, maxdata, title := ds.Min, ds.Max, ds.Title
+	if umin := s.Measures.UserMin; umin >= 0 {
+		mindata = umin
+	}
+	if umax := s.Measures.UserMax; umax >= 0 && umax > mindata {
+		maxdata = umax
+	}
+	if f.LogScale {
+		if horizontal {
+			return errors.New("log scales are vertical: horizontal box and violin plots cannot use them")
+		}
+		var err error
+		mindata, maxdata, err = s.logrange(ds.Data, mindata, maxdata)
+		if err != nil {
+			return err
+		}
+	}
+	mindata, maxdata = expandrange(mindata, maxdata)
+
+	ts := s.Measures.TextSize
+	top, bottom, right := s.Measures.Top, s.Measures.Bottom, s.Measures.Right
+	if s.Measures.Left < 0 {
+		defer func(l float64) { s.Measures.Left = l }(s.Measures.Left)
+		s.Measures.Left = 10.0
+		if horizontal {
+			s.Measures.Left = 30.0
+		}
+	}
+	left := s.Measures.Left
+	linespacing := ts * s.Measures.LineSpacing
+	spacing := ts * 1.5
+	lw := s.Measures.LineWidth
+	if lw <= 0 {
+		lw = 0.2
+	}
+	datacolor := s.Attributes.DataColor
+	labelcolor := s.Attributes.LabelColor
+	valuecolor := s.Attributes.ValueColor
+	df := s.Attributes.DataFmt
+
+	rules, err := s.colorrules()
+	if err != nil {
+		return err
+	}
+	color := func(label string, v float64) string {
+		return rules.color(ChartData{Label: label}, v, datacolor)
+	}
+
+	// each group has a cell along the category axis; the box is half of the cell.
+	// value positions are along the other axis, line widths are relative to the canvas width.
+	n := float64(len(groups))
+	cell := (right - left) / n
+	vlow, vhigh := bottom, top
+	thick := 1.0
+	if horizontal {
+		cell = (top - bottom) / n
+		vlow, vhigh = left, right
+		thick = 1 / s.aspect()
+	}
+	bw := cell * 0.5
+	if barw := s.Measures.BarWidth; barw > 0 && barw < cell {
+		bw = barw
+	}
+	point := func(c, v float64) (float64, float64) {
+		if horizontal {
+			return v, c
+		}
+		return c, v
+	}
+	line := func(c1, v1, c2, v2, w float64, color string) {
+		x1, y1 := point(c1, v1)
+		x2, y2 := point(c2, v2)
+		deck.Line(x1, y1, x2, y2, w, color)
+	}
+	vp := func(v float64) float64 {
+		return s.scale(v, mindata, maxdata, vlow, vhigh)
+	}
+
+	if f.FullDeck {
+		deck.StartSlide(s.Attributes.BackgroundColor)
+	}
+	if chartitle := s.Attributes.ChartTitle; len(chartitle) > 0 {
+		title = xmlesc(chartitle)
+	}
+	if len(title) > 0 && f.ShowTitle {
+		deck.TextMid(left+((right-left)/2), top+(linespacing*1.5), title, "sans", spacing, Titlecolor)
+	}
+	axisbottom := bottom - spacing
+	if f.ShowAxis {
+		if horizontal {
+			s.xaxis(deck, axisbottom, mindata, maxdata)
+		} else {
+			s.yaxis(deck, left-spacing, mindata, maxdata)
+		}
+	}
+
+	for i, g := range groups {
+		c := left + (float64(i)+0.5)*cell
+		if horizontal {
+			c = top - (float64(i)+0.5)*cell
+			deck.TextEnd(left-ts/2, c-(ts/3), nlmap.Replace(g.label), "sans", ts, labelcolor)
+		} else {
+			s.xlabel(deck, c, bottom, g.label, i)
+		}
+		b := newboxstats(g.values)
+		note := fmt.Sprintf("n=%d, quartiles %s to %s", len(g.values), dformat(df, b.q1), dformat(df, b.q3))
+		boxcolor := color(g.label, b.median)
+
+		if f.ShowViolin {
+			// the density outline, scaled to the width of the box,
+			// with the quartiles and median inside
+			if h := kdebandwidth(g.values); h > 0 {
+				const steps = 50
+				lo, hi := g.values[0], g.values[len(g.values)-1]
+				density := make([]float64, steps+1)
+				dmax := 0.0
+				for k := range density {
+					density[k] = kde(g.values, h, lo+(hi-lo)*float64(k)/steps)
+					dmax = math.Max(dmax, density[k])
+				}
+				xs := make([]float64, 0, 2*(steps+1))
+				ys := make([]float64, 0, 2*(steps+1))
+				for k := 0; k <= steps; k++ {
+					x, y := point(c+density[k]/dmax*bw/2, vp(lo+(hi-lo)*float64(k)/steps))
+					xs, ys = append(xs, x), append(ys, y)
+				}
+				for k := steps; k >= 0; k-- {
+					x, y := point(c-density[k]/dmax*bw/2, vp(lo+(hi-lo)*float64(k)/steps))
+					xs, ys = append(xs, x), append(ys, y)
+				}
+				s.tooltip(deck, g.label, b.median, 0, note)
+				deck.Polygon(xs, ys, boxcolor)
+			}
+			line(c, vp(b.lo), c, vp(b.hi), lw, labelcolor)
+			line(c, vp(b.q1), c, vp(b.q3), bw*thick*0.15, labelcolor)
+			mx, my := point(c, vp(b.median))
+			deck.Circle(mx, my, bw*thick*0.12, s.Attributes.BackgroundColor)
+		} else {
+			line(c, vp(b.lo), c, vp(b.q1), lw, labelcolor)
+			line(c, vp(b.q3), c, vp(b.hi), lw, labelcolor)
+			line(c-bw/4, vp(b.lo), c+bw/4, vp(b.lo), lw, labelcolor)
+			line(c-bw/4, vp(b.hi), c+bw/4, vp(b.hi), lw, labelcolor)
+			s.tooltip(deck, g.label, b.median, 0, note)
+			line(c, vp(b.q1), c, vp(b.q3), bw*thick, boxcolor)
+			line(c-bw/2, vp(b.median), c+bw/2, vp(b.median), lw*2, valuecolor)
+		}
+		for _, v := range b.outliers {
+			x, y := point(c, vp(v))
+			s.tooltip(deck, g.label, v, 0, "outlier")
+			deck.Circle(x, y, ts*0.4, color(g.label, v))
+		}
+		if f.ShowValues {
+			ms := dformat(df, b.median)
+			if horizontal {
+				deck.TextMid(vp(b.median), c+bw/2+ts/3, ms, "sans", ts*0.6, valuecolor)
+			} else {
+				deck.Text(c+bw/2+ts/3, vp(b.median)-(ts/4), ms, "sans", ts*0.6, valuecolor)
+			}
+		}
+	}
+
+	if f.ShowLegend {
+		legendbottom := bottom - spacing*2
+		if horizontal {
+			legendbottom = axisbottom - spacing
+		}
+		s.chartlegend(deck, s.condlegenditems(datacolor), barswatch, left, right, top, legendbottom)
+	}
+	if f.FullDeck {
+		deck.EndSlide()
+	}
+	return nil
+}
@@ -5,6 +5,13 @@ Unless specified otherwise, each input source generates a slide in the deck.
 The input data format a tab-separated list of label,data pairs where label is an arbitrary string,
 and data is intepreted as a floating point value. A line beginning with "#" is parsed as a title,
 with the title text beginning after the "#". A third column specifies an annotation.
+Additional numeric columns (label, value1, value2, ...) are drawn as multiple series with a legend;
+a trailing non-numeric column is the annotation. This is a breaking change: a numeric third
+column was formerly the annotation, and is now a second series; -tsvnote reads the third of
+three columns as the annotation, as before. CSV input reads the first two columns
+unless the columns are named with -csvcol (label,value1,value2,...).
+Values must be finite numbers: NaN, Inf, missing values and -csvcol names
+not in the header are data errors (see -strict).
 
 Here is an example input data file:
 
@@ -21,7 +28,17 @@ The command line options are:
 	-min         set the minimum value
 	-max         set the maximum value
 	-csv         read CSV files (default false)
-	-csvcol      specify the columns to use for label,value
+	-strict      stop at the first data error (default false, report warnings and read bad values as zero)
+	-tsvnote     read the third of three TSV columns as the annotation, even if it is a number (default false)
+	-csvcol      specify the columns to use for label,value (label,value1,value2... for multiple series)
+	-series      comma-separated series names (default from the CSV header)
+	-filter      keep values from low to high (low,high, either may be empty)
+	-groupby     combine data with the same label: sum, mean, count, min or max
+	             (heatmaps and grouped treemaps combine cells with the same row and column, or group and label)
+	-sort        sort by label or value, ascending or with desc (for example value,desc)
+	-topn        keep the n largest values, in order, adding the rest as "Other" (not for heatmaps or grouped treemaps)
+	-cumsum      replace values with their cumulative sums (default false)
+	-scolors     space-separated series colors, or the low to high colors of heatmaps (default palette)
 
 	-bar         show bars (default true)
 	-hbar        horizontal chart layout (default false)
@@ -31,26 +48,75 @@ The command line options are:
 	-dot         show dot plot (default false)
 	-lego        show lego chart (default false)
 	-bowtie      show bowtie chart (default false)
+	-candle      show candlestick chart (open,high,low,close columns) (default false)
+	-ohlc        show open-high-low-close chart (default false)
+	-ohlcvol     show volume below candlestick or OHLC charts: the column after the prices named volume,
+	             with -series (for example open,high,low,close,volume) or -csvcol (default false)
+	-upcolor     color of rising prices and waterfall increases (default "rgb(0,128,0)")
+	-downcolor   color of falling prices and waterfall decreases (default "rgb(200,0,0)")
 	-fan         show fanchart (default false)
 	-grid        show gridlines on the y axis (default false)
 	-val         show values (default true)
-	-rline       show a regression line (default false)
+	-rline       show a regression line, one for each series (default false)
+	-rband       show the 95% confidence band of the regression line (default false)
+	-rstats      show the regression equation, R² and number of points (default false)
+	-overlay     trend overlays of the first series (or closing prices), separated by spaces or semicolons,
+	             each kind:params:color:width (empty fields are defaults) and shown in the legend:
+	             sma:n and ema:n (simple and exponential n-point moving averages, default 20),
+	             loess:span (local regression, default 0.3), poly:degree (polynomial fit, default 2),
+	             bollinger:n:k (translucent band of k standard deviations about the n-point average, default 20:2)
 	-frame       show a frame outlining the chart (default false)
-	-datacond    conditional colors (low,high,color)
+	-datacond    conditional colors: rules separated by semicolons, test:color, applied in order;
+	             tests are <v, <=v, >v, >=v, =v, !=v, low..high (inclusive), label~regexp or note~regexp,
+	             for example "<0:red; 0..50:orange; >=50:green" (a single low,high,color range also works);
+	             write \; for a semicolon in a regexp
+	-negcolor    color of negative values not colored by a condition
 	-pct         show percentages with values (default false)
 	-valpos      value position (t=top, b=bottom, m=middle) (default "t")
 	-vol         show volume plot (default false)
+	-stack       stack multiple series as bars or volumes (default false)
+	-stack100    stack multiple series, normalized to 100% (default false)
 	-pgrid       show a proportional grid (default false)
 	-pmap        show proportional map (default false)
 	-donut       show a donut chart (default false)
 	-radial      show a radial chart (default false)
+	-hist        histogram of the values (default false)
+	-bins        histogram bins: sturges, scott, fd (Freedman-Diaconis), or a count (default sturges)
+	-binwidth    histogram bin width, overriding -bins
+	-density     histogram of densities, not counts (default false)
+	-cumulative  cumulative histogram (default false)
+	-normal      show the normal curve with the mean and standard deviation of the values on a histogram (default false)
+	-box         box plot of the values sharing each label: quartiles, median, 1.5 IQR whiskers and outliers; horizontal with -hbar (default false)
+	-violin      violin plot: the kernel density of the values sharing each label, with quartiles and median (default false)
+	-waterfall   waterfall (bridge) chart of a single series: bars float from the running total, starting with the first value;
+	             rows annotated "subtotal" or "total" show the running total (default false)
+	-heatmap     heatmap of row label, column label, value triples, colored on a continuous scale (default false)
+	-calendar    calendar heatmap of daily values, by week and weekday; labels are dates in the -timefmt layout (default false)
+	-treemap     treemap: a two-dimensional proportional map, colored like -pmap (default false)
+	-group       treemap groups: read group, label, value triples and lay out each group together (default false)
 	-spokes      show spokes on the radial chart (default false)
 	-yaxis       show a y axis (default true)
+	-ylog        logarithmic value scale for bar, line, dot, scatter, volume, horizontal and vertical box or violin charts (default false)
+	-logbase     base of the logarithmic scale (default 10)
+	-xy          x/y scatter chart: x,y[,size] values, or a numeric label and value; the annotation is the point color (default false)
+	-xrange      define the x axis range of x/y charts (min,max,step)
 	-yrange      define the y axis range (min,max,step)
+	-o           output format: deck (markup), dsh (decksh), svg, html or png (default "deck")
+	             (png text is in the Go fonts; serif text falls back to Go Regular, a sans face)
 	-fulldeck    generate full markup (default true)
 	-title       show title (default true)
 	-chartitle   specify the title (overiding title in the data)
 	-hline       horizontal line with optional label (value,label)
+	-ref         reference line or band, repeatable, or separated by semicolons: kind:position:color:style:label,
+	             where kind is value (a line at a value), label (a line at a data label or time) or band (a shaded
+	             range of values, low..high), and style is solid, dash or dot; empty fields are defaults.
+	             For example -ref "value:100:red:dash:Target" -ref "band:90..110:green::SLO range".
+	             Value lines are horizontal (vertical on horizontal bar charts); slope charts show values and bands
+	-layout      small multiples: place the charts from all inputs in a grid of rows x cols per slide (for example 2x3)
+	-sharey      use the same value scale for every chart in a layout, with the y axis on the first column (default false)
+	-legend      show a legend for donut, pmap, radial, multi-series and conditional color charts (default false)
+	-legendpos   legend position: top, bottom, left, right, or inside corners tl, tr, bl, br (default top)
+	-legendorient legend orientation: h (rows) or v (columns); the default depends on the position
 	-noteloc     note location (c-center, r-right, l-left, default c)
 
 	-top         top of the plot (default 80)
@@ -62,7 +128,7 @@ The command line options are:
 	-psize       diameter of the donut (default 30)
 
 	-pwidth      width of the donut or proportional map (default 3)
-	-solidpmap   use solid colors for pmaps
+	-solidpmap   use solid colors for pmaps and treemaps
 	-barwidth    barwidth (default computed from the number of data points)
 	-linewidth   linewidth for line charts (default 0.2)
 	-ls          linespacing (default 2.4)
@@ -70,6 +136,8 @@ The command line options are:
 	-xlabel      x axis label interval (default 1, 0 to supress all labels)
 	-xlabrot     x axis label rotation (default 0, no rotation)
 	-xstagger    stagger x axis labels
+	-time        time axis: position data in proportion to time, with calendar ticks (default false)
+	-timefmt     layout for parsing time labels (default "2006-01-02")
 	-xlast       show the last x label
 	-color       data color (default "lightsteelblue")
 	-vcolor      value color (default "rgb(127,0,0)")
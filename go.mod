@@ -2,4 +2,9 @@ module github.com/ajstarks/dchart
 
 go 1.22
 
-require github.com/ajstarks/deckgen v0.0.0-20260109210915-6422203809f5
+require (
+	github.com/ajstarks/deckgen v0.0.0-20260109210915-6422203809f5
+	golang.org/x/image v0.24.0
+)
+
+require golang.org/x/text v0.22.0 // indirect
@@ -0,0 +1,290 @@
+package dchart
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// readtriples reads row label, column label, value triples, tab-separated or CSV.
+// Each triple is data labeled by the row, annotated with the column label.
+// The first line of CSV input is a header if its value is not a number.
+func readtriples(r io.Reader, readcsv, strict bool) (Dataset, error) {
+	ds := Dataset{Min: largest, Max: smallest}
+	title := ""
+	line := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		t := scanner.Text()
+		if len(t) == 0 {
+			continue
+		}
+		if t[0] == '#' && len(t) > 2 {
+			title = strings.TrimSpace(t[1:])
+			if readcsv {
+				title = strings.TrimSpace(strings.TrimPrefix(title, ","))
+			}
+			continue
+		}
+		fields := strings.Split(t, "\t")
+		if readcsv {
+			var err error
+			if fields, err = csv.NewReader(strings.NewReader(t)).Read(); err != nil {
+				perr := &ParseError{Line: line, Err: err}
+				if strict {
+					return ds, perr
+				}
+				ds.Warnings = append(ds.Warnings, perr)
+				continue
+			}
+		}
+		if len(fields) < 3 {
+			continue
+		}
+		v, err := parsenumber(fields[2])
+		if err != nil {
+			if readcsv && line == 1 {
+				continue
+			}
+			perr := &ParseError{Line: line, Column: "3", Text: fields[2], Err: errNumber}
+			if strict {
+				return ds, perr
+			}
+			ds.Warnings = append(ds.Warnings, perr)
+		}
+		d := ChartData{Label: xmlesc(fields[0]), Note: xmlesc(fields[1]), Value: v, Values: []float64{v}}
+		ds.Min, ds.Max = minmax(d.Values, ds.Min, ds.Max)
+		ds.Data = append(ds.Data, d)
+	}
+	ds.Title = xmlesc(title)
+	return ds, scanner.Err()
+}
+
+// colorscale returns the colors of a sequential scale, from low to high values:
+// the series colors, if there are at least two, otherwise blues
+func (s *Settings) colorscale() []string {
+	if colors := strings.Fields(s.Attributes.SeriesColors); len(colors) > 1 {
+		return colors
+	}
+	scale := make([]string, len(blue7))
+	for i, c := range blue7 {
+		scale[len(blue7)-1-i] = c
+	}
+	return scale
+}
+
+// scalecolor returns the color at t (0 to 1) along a color scale,
+// interpolating between the colors of the scale
+func scalecolor(scale []string, t float64) string {
+	t = math.Max(0, math.Min(1, t))
+	p := t * float64(len(scale)-1)
+	i := min(int(p), len(scale)-2)
+	f := p - float64(i)
+	a, b := parsecolor(scale[i]), parsecolor(scale[i+1])
+	mix := func(x, y uint8) int {
+		return int(math.Round(float64(x) + f*(float64(y)-float64(x))))
+	}
+	return fmt.Sprintf("rgb(%d,%d,%d)", mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B))
+}
+
+// contrastcolor returns a text color that is readable on a background color
+func (s *Settings) contrastcolor(bg string) string {
+	c := parsecolor(bg)
+	if 0.299*float64(c.R)+0.587*float64(c.G)+0.114*float64(c.B) < 140 {
+		return "white"
+	}
+	return s.Attributes.LabelColor
+}
+
+// scalelegend shows a color scale for values from dmin to dmax,
+// as a strip of width w, beginning at x, centered vertically on y, labeled below
+func (s *Settings) scalelegend(deck Renderer, scale []string, x, y, w, dmin, dmax float64) {
+	const steps = 50
+	ts := s.Measures.TextSize * 0.75
+	h := ts * s.aspect()
+	sw := w / steps
+	for i := 0; i < steps; i++ {
+		deck.Rect(x+sw*(float64(i)+0.5), y, sw+0.05, h, scalecolor(scale, (float64(i)+0.5)/steps))
+	}
+	axismin, axismax, step := cyrange(dmin, dmax, s.nticks(w))
+	for _, v := range axisvalues(axismin, axismax, step, dmin, dmax) {
+		deck.TextMid(vmap(v, dmin, dmax, x, x+w), y-h/2-ts*1.5, s.axislabel(v, step), "sans", ts, s.Attributes.LabelColor)
+	}
+}
+
+// valuerange returns the range of values for a color scale,
+// overridden by the user minimum and maximum
+func (s *Settings) valuerange(dmin, dmax float64) (float64, float64) {
+	if umin := s.Measures.UserMin; umin >= 0 {
+		dmin = umin
+	}
+	if umax := s.Measures.UserMax; umax >= 0 && umax > dmin {
+		dmax = umax
+	}
+	return expandrange(dmin, dmax)
+}
+
+// heatmap draws a grid of cells colored by value on a continuous scale,
+// with a row for every label and a column for every annotation
+// (the row and column labels of the input triples), in order of appearance.
+func (s *Settings) heatmap(deck Renderer, ds Dataset) error {
+	if len(ds.Data) == 0 {
+		return errors.New("heatmaps need data")
+	}
+	var rows, cols []string
+	rowindex, colindex := map[string]int{}, map[string]int{}
+	for _, d := range ds.Data {
+		if _, ok := rowindex[d.Label]; !ok {
+			rowindex[d.Label] = len(rows)
+			rows = append(rows, d.Label)
+		}
+		if _, ok := colindex[d.Note]; !ok {
+			colindex[d.Note] = len(cols)
+			cols = append(cols, d.Note)
+		}
+	}
+	dmin, dmax := s.valuerange(ds.Min, ds.Max)
+
+	f := s.Flags
+	ts := s.Measures.TextSize
+	top, bottom, right := s.Measures.Top, s.Measures.Bottom, s.Measures.Right
+	left := s.Measures.Left
+	if left < 0 {
+		left = 20.0
+	}
+	labelcolor := s.Attributes.LabelColor
+	df := s.Attributes.DataFmt
+	scale := s.colorscale()
+	cw := (right - left) / float64(len(cols))
+	ch := (top - bottom) / float64(len(rows))
+	gap := 0.2
+
+	if f.FullDeck {
+		deck.StartSlide(s.Attributes.BackgroundColor)
+	}
+	title := ds.Title
+	if chartitle := s.Attributes.ChartTitle; len(chartitle) > 0 {
+		title = xmlesc(chartitle)
+	}
+	if len(title) > 0 && f.ShowTitle {
+		deck.TextMid(left+((right-left)/2), top+(ts*s.Measures.LineSpacing*1.5), title, "sans", ts*1.5, Titlecolor)
+	}
+	for i, r := range rows {
+		deck.TextEnd(left-ts/2, top-(float64(i)+0.5)*ch-(ts/4), nlmap.Replace(r), "sans", ts*0.8, labelcolor)
+	}
+	xint := s.Measures.XLabelInterval
+	for j, c := range cols {
+		if xint > 0 && j%xint == 0 {
+			s.xlabel(deck, left+(float64(j)+0.5)*cw, bottom, c, j)
+		}
+	}
+	for _, d := range ds.Data {
+		x := left + (float64(colindex[d.Note])+0.5)*cw
+		y := top - (float64(rowindex[d.Label])+0.5)*ch
+		color := scalecolor(scale, vmap(d.Value, dmin, dmax, 0, 1))
+		s.tooltip(deck, d.Label+" "+d.Note, d.Value, 0, "")
+		deck.Rect(x, y, cw-gap, ch-gap*s.aspect(), color)
+		if f.ShowValues {
+			deck.TextMid(x, y-(ts/4), dformat(df, d.Value), "sans", ts*0.6, s.contrastcolor(color))
+		}
+	}
+	s.scalelegend(deck, scale, left, bottom-ts*5, math.Min(30, right-left), dmin, dmax)
+	if f.FullDeck {
+		deck.EndSlide()
+	}
+	return nil
+}
+
+// calendar draws daily values as a calendar heatmap: a column for every week
+// (beginning on Sunday) and a row for every weekday, colored by value.
+// Labels are dates in the time format; values on the same day are added.
+func (s *Settings) calendar(deck Renderer, ds Dataset) error {
+	if len(ds.Data) == 0 {
+		return errors.New("calendars need data")
+	}
+	times, err := s.parsetimes(ds.Data)
+	if err != nil {
+		return err
+	}
+	const daykey = "2006-01-02"
+	days := map[string]float64{}
+	dmin, dmax := largest, smallest
+	for i, t := range times {
+		days[t.Format(daykey)] += ds.Data[i].Value
+	}
+	for _, v := range days {
+		dmin, dmax = math.Min(dmin, v), math.Max(dmax, v)
+	}
+	dmin, dmax = s.valuerange(dmin, dmax)
+
+	tmin, tmax := timerange(times)
+	tmin = tmin.Truncate(24 * time.Hour)
+	first := tmin.AddDate(0, 0, -int(tmin.Weekday()))
+	week := func(t time.Time) int {
+		return int(t.Sub(first).Hours()/24) / 7
+	}
+
+	// square cells, as wide as the weeks allow, and fitting seven days
+	f := s.Flags
+	ts := s.Measures.TextSize
+	top, bottom, right := s.Measures.Top, s.Measures.Bottom, s.Measures.Right
+	left := s.Measures.Left
+	if left < 0 {
+		left = 10.0
+	}
+	aspect := s.aspect()
+	w := (right - left) / float64(week(tmax)+1)
+	h := w * aspect
+	if h*7 > top-bottom {
+		h = (top - bottom) / 7
+		w = h / aspect
+	}
+	labelcolor := s.Attributes.LabelColor
+	scale := s.colorscale()
+
+	if f.FullDeck {
+		deck.StartSlide(s.Attributes.BackgroundColor)
+	}
+	title := ds.Title
+	if chartitle := s.Attributes.ChartTitle; len(chartitle) > 0 {
+		title = xmlesc(chartitle)
+	}
+	if len(title) > 0 && f.ShowTitle {
+		deck.TextMid(left+((right-left)/2), top+(ts*s.Measures.LineSpacing*1.5), title, "sans", ts*1.5, Titlecolor)
+	}
+	for _, wd := range []time.Weekday{time.Monday, time.Wednesday, time.Friday} {
+		y := top - (float64(wd)+0.5)*h
+		deck.TextEnd(left-ts/2, y-(ts/4), wd.String()[:3], "sans", ts*0.6, labelcolor)
+	}
+	for t := tmin; !t.After(tmax); t = t.AddDate(0, 0, 1) {
+		x := left + (float64(week(t))+0.5)*w
+		y := top - (float64(t.Weekday())+0.5)*h
+		// label the months, and the years in January
+		if t.Day() == 1 || (t.Equal(tmin) && t.Day() <= 14) {
+			month := t.Format("Jan")
+			if t.Month() == time.January {
+				month = t.Format("Jan 2006")
+			}
+			deck.Text(left+float64(week(t))*w, top+ts*0.5, month, "sans", ts*0.6, labelcolor)
+		}
+		v, ok := days[t.Format(daykey)]
+		if !ok {
+			deck.Rect(x, y, w*0.85, h*0.85, Dotlinecolor, 30)
+			continue
+		}
+		color := scalecolor(scale, vmap(v, dmin, dmax, 0, 1))
+		s.tooltip(deck, t.Format("Mon Jan 2, 2006"), v, 0, "")
+		deck.Rect(x, y, w*0.85, h*0.85, color)
+	}
+	s.scalelegend(deck, scale, left, top-7*h-ts*2, math.Min(30, right-left), dmin, dmax)
+	if f.FullDeck {
+		deck.EndSlide()
+	}
+	return nil
+}
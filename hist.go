@@ -0,0 +1,243 @@
+package dchart
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// maxbins limits the number of histogram bins
+const maxbins = 1000
+
+// quantile returns the q quantile of sorted values, interpolating between values
+func quantile(sorted []float64, q float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	p := q * float64(n-1)
+	i := int(math.Floor(p))
+	if i >= n-1 {
+		return sorted[n-1]
+	}
+	return sorted[i] + (p-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// stddev returns the sample standard deviation
+func stddev(x []float64) float64 {
+	if len(x) < 2 {
+		return 0
+	}
+	m := mean(x)
+	sum := 0.0
+	for _, v := range x {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(x)-1))
+}
+
+// binwidth returns the width of histogram bins for sorted values, using a rule:
+// sturges (the default), scott, fd (Freedman-Diaconis), or a number of bins.
+// Widths from rules are rounded to nice numbers; for a number of bins,
+// the range is divided evenly, and the number is also returned (otherwise zero).
+func binwidth(rule string, sorted []float64) (float64, int, error) {
+	n := float64(len(sorted))
+	r := sorted[len(sorted)-1] - sorted[0]
+	if r == 0 {
+		// a tenth of the value, or one
+		if sorted[0] == 0 {
+			return 1, 0, nil
+		}
+		return nicenum(math.Abs(sorted[0])/10, true), 0, nil
+	}
+	var w float64
+	switch rule {
+	case "", "sturges":
+		w = r / (math.Ceil(math.Log2(n)) + 1)
+	case "scott":
+		w = 3.49 * stddev(sorted) * math.Cbrt(1/n)
+	case "fd":
+		w = 2 * (quantile(sorted, 0.75) - quantile(sorted, 0.25)) * math.Cbrt(1/n)
+		if w == 0 {
+			return binwidth("sturges", sorted)
+		}
+	default:
+		k, err := strconv.Atoi(rule)
+		if err != nil || k < 1 {
+			return 0, 0, fmt.Errorf("%q: bins must be sturges, scott, fd or a number of bins", rule)
+		}
+		return r / float64(k), k, nil
+	}
+	return nicenum(w, true), 0, nil
+}
+
+// histbins counts values into bins of width w, labeled with their ranges.
+// Bins start at a multiple of w, or if the number of bins k is given,
+// there are k bins starting at the minimum.
+// Values are densities if density is set, and accumulate if cumulative is set.
+// Values that are all the same are in a bin centered on the value,
+// between empty bins. The start of the first bin is returned.
+func (s *Settings) histbins(sorted []float64, w float64, k int, density, cumulative bool) ([]ChartData, float64) {
+	start := math.Floor(sorted[0]/w) * w
+	// the last bin includes the maximum
+	nb := int(math.Ceil((sorted[len(sorted)-1] - start) / w))
+	nb = max(1, min(nb, maxbins))
+	if k > 0 {
+		start, nb = sorted[0], k
+	}
+	if sorted[0] == sorted[len(sorted)-1] {
+		start, nb = sorted[0]-w*1.5, 3
+	}
+	counts := make([]float64, nb)
+	for _, v := range sorted {
+		i := int(math.Floor((v - start) / w))
+		if i >= nb {
+			i = nb - 1
+		}
+		counts[i]++
+	}
+	n := float64(len(sorted))
+	df := s.Attributes.DataFmt
+	data := make([]ChartData, nb)
+	sum := 0.0
+	for i, c := range counts {
+		v := c
+		if cumulative {
+			sum += c
+			v = sum
+		}
+		if density {
+			v /= n
+			if !cumulative {
+				v /= w
+			}
+		}
+		lo := start + float64(i)*w
+		data[i] = NewChartData(dformat(df, lo)+"–"+dformat(df, lo+w), "", v)
+	}
+	return data, start
+}
+
+// normalcurve returns the expected histogram values of the normal
+// distribution with mean m and standard deviation sd, at x
+func normalcurve(x, m, sd, n, w float64, density, cumulative bool) float64 {
+	z := (x - m) / sd
+	if cumulative {
+		p := 0.5 * math.Erfc(-z/math.Sqrt2)
+		if density {
+			return p
+		}
+		return n * p
+	}
+	p := math.Exp(-z*z/2) / (sd * math.Sqrt(2*math.Pi))
+	if density {
+		return p
+	}
+	return n * w * p
+}
+
+// histogram bins the values of a dataset, and makes a bar chart of the bins,
+// optionally with the normal curve of the same mean and standard deviation
+func (s *Settings) histogram(deck Renderer, ds Dataset) error {
+	values := make([]float64, 0, len(ds.Data))
+	for _, d := range ds.Data {
+		values = append(values, d.Value)
+	}
+	if len(values) == 0 {
+		return errors.New("histograms need data")
+	}
+	sort.Float64s(values)
+	w := s.Measures.BinWidth
+	k := 0
+	if w <= 0 {
+		var err error
+		if w, k, err = binwidth(s.Attributes.BinRule, values); err != nil {
+			return err
+		}
+	}
+	// bins are widened to fit the limit
+	r := values[len(values)-1] - values[0]
+	switch {
+	case k > maxbins:
+		k, w = maxbins, r/maxbins
+		s.Warnings = append(s.Warnings, fmt.Errorf("histograms have at most %d bins", maxbins))
+	case k == 0 && r/w > maxbins-1:
+		w = nicenum(r/(maxbins-1), false)
+		s.Warnings = append(s.Warnings, fmt.Errorf("histogram bins are widened to %g, for at most %d bins", w, maxbins))
+	}
+	f := s.Flags
+	bins, start := s.histbins(values, w, k, f.Density, f.Cumulative)
+	hist := Dataset{Data: bins, Min: largest, Max: smallest, Title: ds.Title}
+	for _, d := range bins {
+		hist.Min, hist.Max = minmax(d.Values, hist.Min, hist.Max)
+	}
+
+	defer func(show, dmin, fulldeck bool, umin, umax float64) {
+		s.Flags.ShowHistogram, s.Flags.DataMinimum, s.Flags.FullDeck = show, dmin, fulldeck
+		s.Measures.UserMin, s.Measures.UserMax = umin, umax
+	}(f.ShowHistogram, f.DataMinimum, f.FullDeck, s.Measures.UserMin, s.Measures.UserMax)
+	s.Flags.ShowHistogram = false
+	s.Flags.DataMinimum = false
+	if !f.ShowNormal {
+		return s.vchart(deck, hist)
+	}
+	if f.LogScale {
+		s.Warnings = append(s.Warnings, errors.New("no normal curve is shown on a log scale (-normal with -ylog)"))
+		return s.vchart(deck, hist)
+	}
+
+	// the normal curve is drawn on the same scale as the bars
+	const segments = 100
+	n, m, sd := float64(len(values)), mean(values), stddev(values)
+	if sd == 0 {
+		s.Warnings = append(s.Warnings, errors.New("no normal curve is shown for values that are all the same"))
+		return s.vchart(deck, hist)
+	}
+	end := start + float64(len(bins))*w
+	cx := make([]float64, segments+1)
+	cy := make([]float64, segments+1)
+	for i := range cx {
+		cx[i] = start + (end-start)*float64(i)/segments
+		cy[i] = normalcurve(cx[i], m, sd, n, w, f.Density, f.Cumulative)
+		hist.Max = math.Max(hist.Max, cy[i])
+	}
+	if s.Measures.UserMax > 0 {
+		hist.Max = s.Measures.UserMax
+	}
+	s.Measures.UserMin, s.Measures.UserMax = 0, hist.Max
+	fulldeck := s.Flags.FullDeck
+	if fulldeck {
+		deck.StartSlide(s.Attributes.BackgroundColor)
+	}
+	s.Flags.FullDeck = false
+	if err := s.vchart(deck, hist); err != nil {
+		return err
+	}
+
+	// bars are centered on their bins, cumulative values are at the end of bins
+	left, right := s.Measures.Left, s.Measures.Right
+	if left < 0 {
+		left = 10.0
+	}
+	offset := start + w/2
+	if f.Cumulative {
+		offset += w / 2
+	}
+	gap := (right - left) / math.Max(float64(len(bins)-1), 1)
+	bottom, top := s.Measures.Bottom, s.Measures.Top
+	var px, py float64
+	for i := range cx {
+		x := left + ((cx[i]-offset)/w)*gap
+		y := s.scale(cy[i], 0, hist.Max, bottom, top)
+		if i > 0 {
+			deck.Line(px, py, x, y, s.Measures.LineWidth, s.Attributes.RegressionLineColor)
+		}
+		px, py = x, y
+	}
+	if fulldeck {
+		deck.EndSlide()
+	}
+	return nil
+}
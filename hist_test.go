@@ -0,0 +1,131 @@
+package dchart
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBinwidth(t *testing.T) {
+	tests := []struct {
+		rule   string
+		values []float64
+		w      float64
+		k      int
+		err    bool
+	}{
+		{rule: "", values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, w: 2},
+		{rule: "sturges", values: []float64{0, 100}, w: 50},
+		{rule: "scott", values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, w: 5},
+		{rule: "fd", values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, w: 5},
+		{rule: "fd", values: []float64{1, 5, 5, 5, 5, 5, 9}, w: 2}, // no IQR: sturges
+		{rule: "3", values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, w: 3, k: 3},
+		{rule: "4", values: []float64{-1, 1}, w: 0.5, k: 4},
+		{rule: "", values: []float64{0, 0}, w: 1},
+		{rule: "", values: []float64{-250, -250}, w: 20},
+		{rule: "3", values: []float64{7}, w: 0.5},
+		{rule: "0", values: []float64{1, 2}, err: true},
+		{rule: "many", values: []float64{1, 2}, err: true},
+	}
+	for _, test := range tests {
+		w, k, err := binwidth(test.rule, test.values)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q %v: no error", test.rule, test.values)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %v: %v", test.rule, test.values, err)
+			continue
+		}
+		if math.Abs(w-test.w) > 1e-9 || k != test.k {
+			t.Errorf("%q %v: width %v, %d bins, want %v, %d", test.rule, test.values, w, k, test.w, test.k)
+		}
+	}
+}
+
+func TestHistbins(t *testing.T) {
+	tests := []struct {
+		name                string
+		values              []float64
+		w                   float64
+		k                   int
+		density, cumulative bool
+		start               float64
+		labels              []string
+		counts              []float64
+	}{
+		{
+			name:   "nice width",
+			values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			w:      2,
+			start:  0,
+			labels: []string{"0–2", "2–4", "4–6", "6–8", "8–10"},
+			counts: []float64{1, 2, 2, 2, 3},
+		},
+		{
+			name:   "number of bins",
+			values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			w:      3,
+			k:      3,
+			start:  1,
+			labels: []string{"1–4", "4–7", "7–10"},
+			counts: []float64{3, 3, 4},
+		},
+		{
+			name:   "negative",
+			values: []float64{-3, -1, 0.5},
+			w:      1,
+			start:  -3,
+			counts: []float64{1, 0, 1, 1},
+		},
+		{
+			name:   "constant",
+			values: []float64{5, 5, 5},
+			w:      0.5,
+			start:  4.25,
+			labels: []string{"4.2–4.8", "4.8–5.2", "5.2–5.8"},
+			counts: []float64{0, 3, 0},
+		},
+		{
+			name:    "density",
+			values:  []float64{0, 1, 1, 3},
+			w:       2,
+			density: true,
+			counts:  []float64{0.375, 0.125},
+		},
+		{
+			name:       "cumulative",
+			values:     []float64{0, 1, 1, 3},
+			w:          2,
+			cumulative: true,
+			counts:     []float64{3, 4},
+		},
+		{
+			name:       "cumulative density",
+			values:     []float64{0, 1, 1, 3},
+			w:          2,
+			density:    true,
+			cumulative: true,
+			counts:     []float64{0.75, 1},
+		},
+	}
+	s := NewChart("hist", 0, 0, 0, 0)
+	s.Attributes.DataFmt = Defaultfmt
+	for _, test := range tests {
+		bins, start := s.histbins(test.values, test.w, test.k, test.density, test.cumulative)
+		if start != test.start {
+			t.Errorf("%s: start %v, want %v", test.name, start, test.start)
+		}
+		counts := make([]float64, len(bins))
+		for i, b := range bins {
+			counts[i] = b.Value
+			if test.labels != nil && b.Label != test.labels[i] {
+				t.Errorf("%s: bin %d label %q, want %q", test.name, i, b.Label, test.labels[i])
+			}
+		}
+		if !equalfloats(counts, test.counts) {
+			t.Errorf("%s: counts %v, want %v", test.name, counts, test.counts)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package dchart
+
+import (
+	"fmt"
+	"io"
+)
+
+// HTML renders charts as a self-contained HTML page with inline SVG.
+// Chart elements show their data as tooltips; no scripts or external
+// resources are used.
+type HTML struct {
+	*SVG
+	Title string
+	dest  io.Writer
+}
+
+// htmlstyle highlights the elements with tooltips
+const htmlstyle = `body { margin: 0; background: white; font-family: sans-serif }
+svg { display: block; max-width: 100%; height: auto }
+.tip:hover { opacity: 0.7 }`
+
+// NewHTML initializes the HTML renderer, writing to w with the canvas dimensions
+func NewHTML(w io.Writer, width, height float64) *HTML {
+	return &HTML{SVG: NewSVG(w, width, height), Title: "dchart", dest: w}
+}
+
+// EndDeck writes the page, with all slides
+func (p *HTML) EndDeck() {
+	fmt.Fprintf(p.dest, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\"/>\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", xmlesc(p.Title), htmlstyle)
+	p.SVG.EndDeck()
+	fmt.Fprintln(p.dest, "</body>\n</html>")
+}
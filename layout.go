@@ -0,0 +1,155 @@
+package dchart
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseLayout parses a layout specification, "rows x cols" (for example "2x3")
+func ParseLayout(s string) (int, int, error) {
+	f := strings.Split(strings.ToLower(s), "x")
+	if len(f) != 2 {
+		return 0, 0, fmt.Errorf("%q: layout must be rows x cols", s)
+	}
+	rows, rerr := strconv.Atoi(strings.TrimSpace(f[0]))
+	cols, cerr := strconv.Atoi(strings.TrimSpace(f[1]))
+	if rerr != nil || cerr != nil || rows < 1 || cols < 1 {
+		return 0, 0, fmt.Errorf("%q: layout must be rows x cols", s)
+	}
+	return rows, cols, nil
+}
+
+// GenerateLayout makes small multiples: a chart from each Reader, placed
+// in the cells of a grid of rows and columns, filled across then down.
+// When there are more inputs than cells, the grid continues on the next slide.
+func (s *Settings) GenerateLayout(deck Renderer, rows, cols int, r ...io.ReadCloser) error {
+	datasets := make([]Dataset, len(r))
+	for i := range r {
+		ds, err := s.dataset(r[i])
+		if err != nil {
+			for _, rest := range r[i+1:] {
+				rest.Close()
+			}
+			return err
+		}
+		datasets[i] = ds
+	}
+	return s.layout(deck, rows, cols, datasets)
+}
+
+// RenderLayout makes small multiples from datasets in memory, as GenerateLayout
+// does for data read from input. Datasets are made with NewDataset,
+// and are not changed.
+func (s *Settings) RenderLayout(deck Renderer, rows, cols int, datasets ...Dataset) error {
+	transformed := make([]Dataset, len(datasets))
+	for i := range datasets {
+		ds, err := s.transform(datasets[i])
+		if err != nil {
+			return err
+		}
+		transformed[i] = ds
+	}
+	return s.layout(deck, rows, cols, transformed)
+}
+
+// sharedrange returns the range of values across datasets
+func (s *Settings) sharedrange(datasets []Dataset) (float64, float64) {
+	stack := s.Flags.ShowStack || s.Flags.StackPercent
+	lo, hi := largest, smallest
+	for _, ds := range datasets {
+		dmin, dmax := ds.Min, ds.Max
+		if stack {
+			dmin, dmax = stackrange(ds.Data, nseries(ds.Data), s.Flags.StackPercent)
+		}
+		lo, hi = math.Min(lo, dmin), math.Max(hi, dmax)
+	}
+	return lo, hi
+}
+
+// layout places charts in a grid of cells within the chart boundary.
+// A chart title is shown once, above the grid; each panel shows its dataset's title.
+// With SharedScale, every panel uses the same value range, and only
+// the panels in the first column show the y axis.
+func (s *Settings) layout(deck Renderer, rows, cols int, datasets []Dataset) error {
+	if rows < 1 || cols < 1 {
+		return fmt.Errorf("%dx%d: layout must have at least one row and column", rows, cols)
+	}
+	left, right, top, bottom := s.Measures.Left, s.Measures.Right, s.Measures.Top, s.Measures.Bottom
+	if left < 0 {
+		left = 10.0
+	}
+	ts := s.Measures.TextSize
+	fulldeck := s.Flags.FullDeck
+	showaxis := s.Flags.ShowAxis
+	title := xmlesc(s.Attributes.ChartTitle)
+
+	// each panel is drawn with its own settings: the measures of its cell,
+	// and scaled text and sizes
+	panel := *s
+	panel.Warnings = nil
+	if s.Flags.SharedScale && len(datasets) > 0 {
+		dmin, dmax := s.sharedrange(datasets)
+		for i := range datasets {
+			datasets[i].Min, datasets[i].Max = dmin, dmax
+		}
+		if panel.Measures.UserMax < 0 {
+			panel.Measures.UserMax = dmax
+		}
+	}
+
+	// panels are scaled to the size of their cells, leaving room for
+	// the panel title above, and x labels below
+	n := float64(max(rows, cols))
+	pts := ts / math.Sqrt(n)
+	cw := (right - left) / float64(cols)
+	ch := (top - bottom) / float64(rows)
+	hgap := cw * 0.1
+	tgap := pts * s.Measures.LineSpacing * 2
+	bgap := pts * 4
+
+	panel.Measures.TextSize = pts
+	panel.Measures.PSize /= n
+	panel.Measures.PWidth /= n
+	f := s.Flags
+	circular := f.ShowDonut || f.ShowRadial || f.ShowFan || f.ShowBowtie
+	panel.Attributes.ChartTitle = ""
+	panel.Flags.FullDeck = false
+	cells := rows * cols
+	for i, ds := range datasets {
+		cell := i % cells
+		if cell == 0 && fulldeck {
+			if i > 0 {
+				deck.EndSlide()
+			}
+			deck.StartSlide(s.Attributes.BackgroundColor)
+		}
+		if cell == 0 && len(title) > 0 && s.Flags.ShowTitle {
+			deck.TextMid(left+(right-left)/2, top+ts*2, title, "sans", ts*1.5, Titlecolor)
+		}
+		row, col := cell/cols, cell%cols
+		x := left + float64(col)*cw
+		y := top - float64(row)*ch
+		panel.Measures.Left = x + hgap
+		panel.Measures.Right = x + cw - hgap
+		panel.Measures.Top = y - tgap
+		panel.Measures.Bottom = y - ch + bgap
+		if circular {
+			// circular charts are centered in their cell
+			panel.Measures.Left = x + cw/2
+		}
+		panel.Flags.ShowAxis = showaxis && (!s.Flags.SharedScale || col == 0)
+		err := panel.render(deck, ds)
+		s.Warnings = append(s.Warnings, panel.Warnings...)
+		panel.Warnings = nil
+		if err != nil {
+			return err
+		}
+	}
+	if fulldeck && len(datasets) > 0 {
+		deck.EndSlide()
+	}
+	return nil
+}
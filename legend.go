@@ -0,0 +1,180 @@
+package dchart
+
+import (
+	"html"
+	"math"
+)
+
+// legenditem is a legend entry: a label with the color (and opacity) of its swatch,
+// and the shape of the swatch, if it differs from the chart's
+type legenditem struct {
+	label   string
+	color   string
+	opacity float64
+	swatch  string
+}
+
+// swatch shapes, matching the chart's data elements
+const (
+	barswatch  = "bar"
+	lineswatch = "line"
+	dotswatch  = "dot"
+)
+
+// legendwidth estimates the width of a legend entry
+func legendwidth(label string, ts float64) float64 {
+	return ts*2.5 + float64(len([]rune(html.UnescapeString(label))))*ts*0.6
+}
+
+// aspect returns the aspect ratio (width/height) of the canvas
+func (s *Settings) aspect() float64 {
+	if s.Measures.CanvasWidth <= 0 || s.Measures.CanvasHeight <= 0 {
+		return 792.0 / 612.0
+	}
+	return s.Measures.CanvasWidth / s.Measures.CanvasHeight
+}
+
+// chartlegend draws a legend for the chart occupying left, right, top and bottom
+// (including its labels).
+// The legend is placed at the top (the default), bottom, left, right or an inside
+// corner (tl, tr, bl, br); entries are in rows (h) or columns (v), wrapping
+// to fit the space available.
+func (s *Settings) chartlegend(deck Renderer, items []legenditem, swatch string, left, right, top, bottom float64) {
+	if len(items) == 0 {
+		return
+	}
+	ts := s.Measures.TextSize * 0.75
+	rowh := ts * 2
+	pad := ts
+
+	pos := s.Attributes.LegendPosition
+	orient := s.Attributes.LegendOrientation
+	if len(orient) == 0 {
+		orient = "h"
+		if pos != "top" && pos != "bottom" && pos != "" {
+			orient = "v"
+		}
+	}
+
+	// the space available
+	xlimit, ylimit := right-left, top-bottom
+	switch pos {
+	case "left":
+		xlimit = left - pad*2
+	case "right":
+		xlimit = 100 - right - pad*2
+	case "top", "bottom", "":
+		ylimit = 100
+	}
+
+	// lay out entries relative to the top left corner of the legend
+	rx := make([]float64, len(items))
+	ry := make([]float64, len(items))
+	var x, y, colw, width, height float64
+	for i, item := range items {
+		w := legendwidth(item.label, ts)
+		if orient == "v" {
+			if y+rowh > ylimit && i > 0 {
+				x += colw
+				y, colw = 0, 0
+			}
+			rx[i], ry[i] = x, y
+			y += rowh
+			colw = math.Max(colw, w)
+			width, height = math.Max(width, x+colw), math.Max(height, y)
+			continue
+		}
+		if x+w > xlimit && i > 0 {
+			x = 0
+			y += rowh
+		}
+		rx[i], ry[i] = x, y
+		x += w
+		width, height = math.Max(width, x), y+rowh
+	}
+
+	// place the legend, x0, y0 is the top left
+	var x0, y0 float64
+	switch pos {
+	case "bottom":
+		x0, y0 = left, bottom-pad
+	case "left":
+		x0, y0 = math.Max(left-pad*2-width, 1), top
+	case "right":
+		x0, y0 = right+pad*2, top
+	case "tl":
+		x0, y0 = left+pad, top-pad
+	case "tr":
+		x0, y0 = right-pad-width, top-pad
+	case "bl":
+		x0, y0 = left+pad, bottom+pad+height
+	case "br":
+		x0, y0 = right-pad-width, bottom+pad+height
+	default:
+		x0, y0 = left, top+height
+	}
+
+	labelcolor := s.Attributes.LabelColor
+	for i, item := range items {
+		x, y := x0+rx[i], y0-ry[i]-(rowh/2)
+		cy := y + ts*0.3
+		shape := swatch
+		if len(item.swatch) > 0 {
+			shape = item.swatch
+		}
+		switch shape {
+		case lineswatch:
+			deck.Line(x, cy, x+ts*1.5, cy, ts/4, item.color, item.opacity)
+		case dotswatch:
+			deck.Circle(x+ts*0.75, cy, ts, item.color, item.opacity)
+		default:
+			deck.Square(x+ts*0.75, cy, ts, item.color, item.opacity)
+		}
+		deck.Text(x+ts*2, y, item.label, "sans", ts, labelcolor)
+	}
+}
+
+// serieslegenditems makes legend entries for n series
+func (s *Settings) serieslegenditems(n int, colors []string) []legenditem {
+	var items []legenditem
+	if n < 2 {
+		return items
+	}
+	for i, name := range s.seriesnames(n) {
+		items = append(items, legenditem{label: name, color: colors[i], opacity: 100})
+	}
+	return items
+}
+
+// condlegenditems makes legend entries for conditional data colors:
+// the data color, and the colors of the rules
+func (s *Settings) condlegenditems(datacolor string) []legenditem {
+	rules, err := s.colorrules()
+	if err != nil || !rules.active() {
+		return nil
+	}
+	items := []legenditem{{label: "other", color: datacolor, opacity: 100}}
+	return append(items, rules.legenditems()...)
+}
+
+// valuelegenditems makes legend entries for charts of values:
+// the series, and the conditional color, if any
+func (s *Settings) valuelegenditems(n int, colors []string) []legenditem {
+	items := s.serieslegenditems(n, colors)
+	cond := s.condlegenditems(colors[0])
+	if len(cond) > 0 && n > 1 {
+		return append(items, cond[1:]...)
+	}
+	return append(items, cond...)
+}
+
+// datalegenditems makes legend entries for every data item,
+// using the color of its element
+func datalegenditems(data []ChartData, color func(i int) (string, float64)) []legenditem {
+	items := make([]legenditem, len(data))
+	for i, d := range data {
+		c, op := color(i)
+		items[i] = legenditem{label: d.Label, color: c, opacity: op}
+	}
+	return items
+}
@@ -0,0 +1,338 @@
+package dchart
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// overlay is a trend drawn over the data: a moving average, smoothing, fit or band
+type overlay struct {
+	kind   string
+	params []float64
+	color  string
+	width  float64
+}
+
+// overlayparams are the number of parameters of each kind of overlay, and their defaults
+var overlayparams = map[string][]float64{
+	"sma":       {20},    // points
+	"ema":       {20},    // points
+	"loess":     {0.3},   // span, the fraction of points in each local fit
+	"poly":      {2},     // degree
+	"bollinger": {20, 2}, // points, standard deviations
+}
+
+// parseoverlays parses overlay specifications, separated by spaces or semicolons:
+// kind:params:color:width, where kind is sma, ema, loess, poly or bollinger,
+// followed by its parameters. Empty or missing fields are the defaults;
+// colors are from the series palette, following the series.
+// For example "sma:50 ema:20:red loess:0.25 poly:3 bollinger:20:2:gray:0.1"
+func (s *Settings) parseoverlays(ns int) ([]overlay, error) {
+	specs := strings.FieldsFunc(s.Attributes.Overlays, func(r rune) bool { return r == ';' || r == ' ' })
+	overlays := make([]overlay, len(specs))
+	lw := s.Measures.LineWidth
+	if lw <= 0 {
+		lw = 0.2
+	}
+	for i, spec := range specs {
+		f := strings.Split(spec, ":")
+		defaults, ok := overlayparams[f[0]]
+		if !ok {
+			return nil, fmt.Errorf("%q: overlays are sma, ema, loess, poly or bollinger", spec)
+		}
+		o := overlay{kind: f[0], params: append([]float64{}, defaults...), width: lw}
+		o.color = seriespalette[(ns+i)%len(seriespalette)]
+		for k, field := range f[1:] {
+			if len(field) == 0 {
+				continue
+			}
+			var err error
+			switch {
+			case k < len(o.params):
+				o.params[k], err = strconv.ParseFloat(field, 64)
+			case k == len(o.params):
+				o.color = field
+			case k == len(o.params)+1:
+				o.width, err = strconv.ParseFloat(field, 64)
+			default:
+				err = errors.New("too many fields")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%q: bad overlay (kind:params:color:width)", spec)
+			}
+		}
+		if o.kind == "loess" && (o.params[0] <= 0 || o.params[0] > 1) || o.kind != "loess" && o.params[0] < 1 {
+			return nil, fmt.Errorf("%q: bad overlay parameter", spec)
+		}
+		overlays[i] = o
+	}
+	return overlays, nil
+}
+
+// label describes an overlay in the legend
+func (o overlay) label() string {
+	p := o.params
+	switch o.kind {
+	case "sma":
+		return fmt.Sprintf("%g-point moving average", p[0])
+	case "ema":
+		return fmt.Sprintf("%g-point exponential average", p[0])
+	case "loess":
+		return fmt.Sprintf("LOESS (span %g)", p[0])
+	case "poly":
+		return fmt.Sprintf("degree %g fit", p[0])
+	default:
+		return fmt.Sprintf("Bollinger bands (%g points, ±%gσ)", p[0], p[1])
+	}
+}
+
+// sma returns the simple moving average of the n points ending at each point,
+// beginning with the nth point
+func sma(y []float64, n int) []float64 {
+	var avg []float64
+	sum := 0.0
+	for i, v := range y {
+		sum += v
+		if i >= n {
+			sum -= y[i-n]
+		}
+		if i >= n-1 {
+			avg = append(avg, sum/float64(n))
+		}
+	}
+	return avg
+}
+
+// ema returns the exponential moving average over n points,
+// beginning with the first point
+func ema(y []float64, n int) []float64 {
+	alpha := 2 / (float64(n) + 1)
+	avg := make([]float64, len(y))
+	for i, v := range y {
+		if i == 0 {
+			avg[i] = v
+			continue
+		}
+		avg[i] = alpha*v + (1-alpha)*avg[i-1]
+	}
+	return avg
+}
+
+// loess returns the locally weighted linear regression (with tricube weights)
+// at each point, using the nearest fraction (span) of the points
+func loess(x, y []float64, span float64) []float64 {
+	n := len(x)
+	k := min(max(int(math.Ceil(span*float64(n))), min(3, n)), n)
+	fit := make([]float64, n)
+	dist := make([]float64, n)
+	sorted := make([]float64, n)
+	for i := range x {
+		for j := range x {
+			dist[j] = math.Abs(x[j] - x[i])
+		}
+		copy(sorted, dist)
+		sort.Float64s(sorted)
+		h := sorted[k-1] * 1.000001
+		// x is relative to the point, so the fit is the intercept
+		var sw, swx, swy, swxx, swxy float64
+		for j := range x {
+			w := 1.0
+			if h > 0 {
+				u := dist[j] / h
+				if u >= 1 {
+					continue
+				}
+				w = math.Pow(1-u*u*u, 3)
+			} else if dist[j] > 0 {
+				continue
+			}
+			dx := x[j] - x[i]
+			sw += w
+			swx += w * dx
+			swy += w * y[j]
+			swxx += w * dx * dx
+			swxy += w * dx * y[j]
+		}
+		denom := sw*swxx - swx*swx
+		if denom <= 1e-12*sw*swxx {
+			fit[i] = swy / sw
+			continue
+		}
+		b := (sw*swxy - swx*swy) / denom
+		fit[i] = (swy - b*swx) / sw
+	}
+	return fit
+}
+
+// polyfit returns the least-squares polynomial of a degree through x, y
+func polyfit(x, y []float64, degree int) (func(float64) float64, error) {
+	if degree >= len(x) {
+		return nil, fmt.Errorf("a degree %d fit needs more than %d points", degree, degree)
+	}
+	// x is scaled to -1..1 for accuracy
+	xmin, xmax := minmax(x, largest, smallest)
+	mid, half := (xmin+xmax)/2, (xmax-xmin)/2
+	if half == 0 {
+		half = 1
+	}
+	m := degree + 1
+	a := make([][]float64, m)
+	for r := range a {
+		a[r] = make([]float64, m+1)
+	}
+	for i := range x {
+		t := (x[i] - mid) / half
+		pow := make([]float64, 2*m)
+		pow[0] = 1
+		for k := 1; k < len(pow); k++ {
+			pow[k] = pow[k-1] * t
+		}
+		for r := 0; r < m; r++ {
+			for c := 0; c < m; c++ {
+				a[r][c] += pow[r+c]
+			}
+			a[r][m] += y[i] * pow[r]
+		}
+	}
+	// gaussian elimination with partial pivoting
+	for c := 0; c < m; c++ {
+		p := c
+		for r := c + 1; r < m; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[p][c]) {
+				p = r
+			}
+		}
+		if math.Abs(a[p][c]) < 1e-12 {
+			return nil, fmt.Errorf("a degree %d fit is undetermined by the data", degree)
+		}
+		a[c], a[p] = a[p], a[c]
+		for r := c + 1; r < m; r++ {
+			f := a[r][c] / a[c][c]
+			for k := c; k <= m; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+		}
+	}
+	coef := make([]float64, m)
+	for r := m - 1; r >= 0; r-- {
+		v := a[r][m]
+		for k := r + 1; k < m; k++ {
+			v -= a[r][k] * coef[k]
+		}
+		coef[r] = v / a[r][r]
+	}
+	return func(x float64) float64 {
+		t := (x - mid) / half
+		v := 0.0
+		for k := m - 1; k >= 0; k-- {
+			v = v*t + coef[k]
+		}
+		return v
+	}, nil
+}
+
+// bollinger returns the moving average of n points, and the bands k
+// standard deviations below and above it, beginning with the nth point
+func bollinger(y []float64, n int, k float64) ([]float64, []float64, []float64) {
+	avg := sma(y, n)
+	lo := make([]float64, len(avg))
+	hi := make([]float64, len(avg))
+	for i, m := range avg {
+		ss := 0.0
+		for _, v := range y[i : i+n] {
+			ss += (v - m) * (v - m)
+		}
+		sd := math.Sqrt(ss / float64(n))
+		lo[i], hi[i] = m-k*sd, m+k*sd
+	}
+	return avg, lo, hi
+}
+
+// overlaylegenditems makes legend entries for overlays
+func overlaylegenditems(overlays []overlay) []legenditem {
+	items := make([]legenditem, len(overlays))
+	for i, o := range overlays {
+		items[i] = legenditem{label: o.label(), color: o.color, opacity: 100, swatch: lineswatch}
+		if o.kind == "bollinger" {
+			items[i].swatch, items[i].opacity = barswatch, 30
+		}
+	}
+	return items
+}
+
+// drawoverlays draws overlays of the values y, positioned by index or time
+// like the chart data; bands are translucent polygons.
+// Fits that cannot be made from the data are reported as warnings.
+func (s *Settings) drawoverlays(deck Renderer, overlays []overlay, y []float64, times []time.Time, mindata, maxdata float64) {
+	l := len(y)
+	if l < 2 {
+		return
+	}
+	left, right := s.Measures.Left, s.Measures.Right
+	if left < 0 {
+		left = 10.0
+	}
+	top, bottom := s.Measures.Top, s.Measures.Bottom
+	x := make([]float64, l)
+	for i := range x {
+		x[i] = float64(i)
+		if times != nil {
+			x[i] = float64(times[i].Unix())
+		}
+	}
+	xmin, xmax := minmax(x, largest, smallest)
+	xp := func(v float64) float64 { return vmap(v, xmin, xmax, left, right) }
+	yp := func(v float64) float64 { return s.scale(v, mindata, maxdata, bottom, top) }
+	polyline := func(px, py []float64, o overlay) {
+		for i := 1; i < len(px); i++ {
+			deck.Line(xp(px[i-1]), yp(py[i-1]), xp(px[i]), yp(py[i]), o.width, o.color)
+		}
+	}
+
+	for _, o := range overlays {
+		n := int(o.params[0])
+		switch o.kind {
+		case "sma":
+			if n <= l {
+				polyline(x[n-1:], sma(y, n), o)
+			}
+		case "ema":
+			polyline(x, ema(y, n), o)
+		case "loess":
+			polyline(x, loess(x, y, o.params[0]), o)
+		case "poly":
+			fit, err := polyfit(x, y, n)
+			if err != nil {
+				s.Warnings = append(s.Warnings, err)
+				continue
+			}
+			const segments = 100
+			px := make([]float64, segments+1)
+			py := make([]float64, segments+1)
+			for i := range px {
+				px[i] = xmin + (xmax-xmin)*float64(i)/segments
+				py[i] = fit(px[i])
+			}
+			polyline(px, py, o)
+		case "bollinger":
+			if n > l {
+				continue
+			}
+			avg, lo, hi := bollinger(y, n, o.params[1])
+			var bx, by []float64
+			for i := range hi {
+				bx, by = append(bx, xp(x[n-1+i])), append(by, yp(hi[i]))
+			}
+			for i := len(lo) - 1; i >= 0; i-- {
+				bx, by = append(bx, xp(x[n-1+i])), append(by, yp(lo[i]))
+			}
+			deck.Polygon(bx, by, o.color, 20)
+			polyline(x[n-1:], avg, o)
+		}
+	}
+}
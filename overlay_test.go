@@ -0,0 +1,117 @@
+package dchart
+
+import "testing"
+
+func TestMovingAverages(t *testing.T) {
+	y := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		name string
+		got  []float64
+		want []float64
+	}{
+		{"sma 3", sma(y, 3), []float64{2, 3, 4}},
+		{"sma 1", sma(y, 1), y},
+		{"sma 5", sma(y, 5), []float64{3}},
+		{"sma 6", sma(y, 6), nil},
+		{"sma empty", sma(nil, 3), nil},
+		{"ema 3", ema([]float64{1, 2, 3}, 3), []float64{1, 1.5, 2.25}},
+		{"ema 1", ema(y, 1), y},
+		{"ema empty", ema(nil, 3), []float64{}},
+	}
+	for _, test := range tests {
+		if !equalfloats(test.got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestLoess(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y []float64
+		span float64
+		want []float64
+	}{
+		{"line", []float64{1, 2, 3, 4, 5, 6}, []float64{3, 5, 7, 9, 11, 13}, 0.5, []float64{3, 5, 7, 9, 11, 13}},
+		{"level", []float64{1, 3, 4, 8}, []float64{2, 2, 2, 2}, 0.75, []float64{2, 2, 2, 2}},
+		{"same x", []float64{1, 1, 1}, []float64{1, 2, 3}, 0.5, []float64{2, 2, 2}},
+		{"one point", []float64{5}, []float64{3}, 0.5, []float64{3}},
+		{"two points", []float64{0, 1}, []float64{1, 3}, 0.1, []float64{1, 3}},
+	}
+	for _, test := range tests {
+		if got := loess(test.x, test.y, test.span); !equalfloats(got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPolyfit(t *testing.T) {
+	tests := []struct {
+		name   string
+		x, y   []float64
+		degree int
+		at     []float64
+		want   []float64
+		err    bool
+	}{
+		{
+			name: "quadratic", x: []float64{0, 1, 2, 3, 4}, y: []float64{1, 0, 1, 4, 9}, degree: 2,
+			at: []float64{5, -1, 1.5}, want: []float64{16, 4, 0.25},
+		},
+		{
+			name: "line", x: []float64{10, 20}, y: []float64{1, 2}, degree: 1,
+			at: []float64{0, 30}, want: []float64{0, 3},
+		},
+		{
+			name: "least squares", x: []float64{1, 2, 3, 4}, y: []float64{1, 3, 2, 4}, degree: 1,
+			at: []float64{0, 2.5}, want: []float64{0.5, 2.5},
+		},
+		{
+			name: "constant", x: []float64{3, 3}, y: []float64{1, 5}, degree: 0,
+			at: []float64{0}, want: []float64{3},
+		},
+		{name: "too few points", x: []float64{1, 2}, y: []float64{1, 2}, degree: 2, err: true},
+		{name: "same x", x: []float64{2, 2, 2}, y: []float64{1, 2, 3}, degree: 1, err: true},
+	}
+	for _, test := range tests {
+		f, err := polyfit(test.x, test.y, test.degree)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := make([]float64, len(test.at))
+		for i, x := range test.at {
+			got[i] = f(x)
+		}
+		if !equalfloats(got, test.want) {
+			t.Errorf("%s: values at %v are %v, want %v", test.name, test.at, got, test.want)
+		}
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	tests := []struct {
+		name        string
+		y           []float64
+		n           int
+		k           float64
+		avg, lo, hi []float64
+	}{
+		{"population deviation", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2, []float64{5}, []float64{1}, []float64{9}},
+		{"moving", []float64{1, 3, 5}, 2, 1, []float64{2, 4}, []float64{1, 3}, []float64{3, 5}},
+		{"level", []float64{4, 4, 4}, 2, 2, []float64{4, 4}, []float64{4, 4}, []float64{4, 4}},
+		{"too few points", []float64{1, 2}, 3, 2, nil, []float64{}, []float64{}},
+	}
+	for _, test := range tests {
+		avg, lo, hi := bollinger(test.y, test.n, test.k)
+		if !equalfloats(avg, test.avg) || !equalfloats(lo, test.lo) || !equalfloats(hi, test.hi) {
+			t.Errorf("%s: %v, %v, %v, want %v, %v, %v", test.name, avg, lo, hi, test.avg, test.lo, test.hi)
+		}
+	}
+}
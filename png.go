@@ -0,0 +1,376 @@
+package dchart
+
+import (
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// PNG renders charts as a PNG image, drawn in pure Go. Each slide is a canvas
+// of the specified width and height (pixels); multiple slides are stacked vertically.
+type PNG struct {
+	Width, Height float64
+	dest          io.Writer
+	img           *image.RGBA
+	slides        []*image.RGBA
+	fonts         map[string]*sfnt.Font
+	raster        vector.Rasterizer
+	sbuf          sfnt.Buffer
+	err           error
+}
+
+// NewPNG initializes the PNG renderer, writing to w with the canvas dimensions.
+// Text uses the Go fonts: Go Regular for sans, Go Mono for mono.
+// The Go fonts have no serif face, so serif text is also Go Regular
+// unless a serif font is loaded with LoadFont.
+func NewPNG(w io.Writer, width, height float64) *PNG {
+	if width <= 0 || height <= 0 {
+		width, height = 792, 612
+	}
+	p := &PNG{dest: w, Width: width, Height: height, fonts: map[string]*sfnt.Font{}}
+	for name, ttf := range map[string][]byte{"sans": goregular.TTF, "serif": goregular.TTF, "mono": gomono.TTF} {
+		p.LoadFont(name, ttf)
+	}
+	return p
+}
+
+// LoadFont sets the font (TrueType or OpenType data) used for the named family
+func (p *PNG) LoadFont(name string, data []byte) error {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return err
+	}
+	p.fonts[name] = f
+	return nil
+}
+
+// pngcolor converts a deck color to a color with the specified opacity percentage
+func pngcolor(s string, opacity []float64) color.NRGBA {
+	c := parsecolor(s)
+	c.A = uint8(math.Round(svgopacity(opacity) * 255))
+	return c
+}
+
+// px converts a percentage coordinate to pixels
+func (p *PNG) px(x, y float64) (float64, float64) {
+	return (x / 100) * p.Width, ((100 - y) / 100) * p.Height
+}
+
+// pw converts a percentage of the width to pixels
+func (p *PNG) pw(w float64) float64 {
+	return (w / 100) * p.Width
+}
+
+// path is a sequence of outline segments in pixel coordinates
+type path []sfnt.Segment
+
+func (pa *path) moveTo(x, y float64) {
+	*pa = append(*pa, sfnt.Segment{Op: sfnt.SegmentOpMoveTo, Args: [3]fixed.Point26_6{fpoint(x, y)}})
+}
+
+func (pa *path) lineTo(x, y float64) {
+	*pa = append(*pa, sfnt.Segment{Op: sfnt.SegmentOpLineTo, Args: [3]fixed.Point26_6{fpoint(x, y)}})
+}
+
+// segpoints returns the number of points used by a segment
+func segpoints(seg sfnt.Segment) int {
+	switch seg.Op {
+	case sfnt.SegmentOpQuadTo:
+		return 2
+	case sfnt.SegmentOpCubeTo:
+		return 3
+	}
+	return 1
+}
+
+// fpoint makes a fixed point from pixel coordinates
+func fpoint(x, y float64) fixed.Point26_6 {
+	return fixed.Point26_6{X: fixed.Int26_6(math.Round(x * 64)), Y: fixed.Int26_6(math.Round(y * 64))}
+}
+
+// fill draws the path, filled with the color, on the current slide.
+// Only the bounding box of the path is rasterized.
+func (p *PNG) fill(pa path, c color.NRGBA) {
+	if len(pa) == 0 || c.A == 0 {
+		return
+	}
+	p.slide()
+	bounds := fixed.Rectangle26_6{Min: pa[0].Args[0], Max: pa[0].Args[0]}
+	for _, seg := range pa {
+		for _, pt := range seg.Args[:segpoints(seg)] {
+			bounds.Min.X, bounds.Max.X = min(bounds.Min.X, pt.X), max(bounds.Max.X, pt.X)
+			bounds.Min.Y, bounds.Max.Y = min(bounds.Min.Y, pt.Y), max(bounds.Max.Y, pt.Y)
+		}
+	}
+	r := image.Rect(bounds.Min.X.Floor(), bounds.Min.Y.Floor(), bounds.Max.X.Ceil()+1, bounds.Max.Y.Ceil()+1).Intersect(p.img.Bounds())
+	if r.Empty() {
+		return
+	}
+	ox, oy := float32(r.Min.X), float32(r.Min.Y)
+	pt := func(a fixed.Point26_6) (float32, float32) {
+		return float32(a.X)/64 - ox, float32(a.Y)/64 - oy
+	}
+	z := &p.raster
+	z.Reset(r.Dx(), r.Dy())
+	for _, seg := range pa {
+		x0, y0 := pt(seg.Args[0])
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			z.ClosePath()
+			z.MoveTo(x0, y0)
+		case sfnt.SegmentOpLineTo:
+			z.LineTo(x0, y0)
+		case sfnt.SegmentOpQuadTo:
+			x1, y1 := pt(seg.Args[1])
+			z.QuadTo(x0, y0, x1, y1)
+		case sfnt.SegmentOpCubeTo:
+			x1, y1 := pt(seg.Args[1])
+			x2, y2 := pt(seg.Args[2])
+			z.CubeTo(x0, y0, x1, y1, x2, y2)
+		}
+	}
+	z.ClosePath()
+	z.Draw(p.img, r, image.NewUniform(c), image.Point{})
+}
+
+// slide makes sure there is a current slide to draw on
+func (p *PNG) slide() {
+	if p.img == nil {
+		p.img = image.NewRGBA(image.Rect(0, 0, int(math.Round(p.Width)), int(math.Round(p.Height))))
+	}
+}
+
+// StartDeck begins the document
+func (p *PNG) StartDeck() {
+	p.img = nil
+	p.slides = nil
+}
+
+// EndDeck encodes the image, with all slides
+func (p *PNG) EndDeck() {
+	if p.img != nil {
+		p.EndSlide()
+	}
+	if len(p.slides) == 0 {
+		p.slide()
+		p.EndSlide()
+	}
+	b := p.slides[0].Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()*len(p.slides)))
+	for i, s := range p.slides {
+		draw.Draw(out, b.Add(image.Pt(0, b.Dy()*i)), s, image.Point{}, draw.Src)
+	}
+	p.err = png.Encode(p.dest, out)
+}
+
+// Err returns the error, if any, from writing the image at the end of the deck
+func (p *PNG) Err() error {
+	return p.err
+}
+
+// StartSlide begins a slide, with an optional background color
+func (p *PNG) StartSlide(colors ...string) {
+	if p.img != nil {
+		p.EndSlide()
+	}
+	p.slide()
+	if len(colors) > 0 && len(colors[0]) > 0 {
+		draw.Draw(p.img, p.img.Bounds(), image.NewUniform(pngcolor(colors[0], nil)), image.Point{}, draw.Src)
+	}
+}
+
+// EndSlide ends a slide
+func (p *PNG) EndSlide() {
+	p.slide()
+	p.slides = append(p.slides, p.img)
+	p.img = nil
+}
+
+// text places text with the specified anchor (0=start, 0.5=middle, 1=end) and rotation
+func (p *PNG) text(x, y float64, s string, anchor float64, fontname string, rotation, size float64, color string, opacity []float64) {
+	f, ok := p.fonts[fontname]
+	if !ok {
+		f = p.fonts["sans"]
+	}
+	if f == nil || size <= 0 {
+		return
+	}
+	s = html.UnescapeString(s)
+	ppem := fixed.Int26_6(math.Round(p.pw(size) * 64))
+
+	// glyph indices and horizontal positions, for measuring before drawing
+	type glyph struct {
+		index sfnt.GlyphIndex
+		x     fixed.Int26_6
+	}
+	var glyphs []glyph
+	var dot fixed.Int26_6
+	prev := sfnt.GlyphIndex(0)
+	for _, r := range s {
+		g, err := f.GlyphIndex(&p.sbuf, r)
+		if err != nil {
+			continue
+		}
+		if prev != 0 {
+			if k, err := f.Kern(&p.sbuf, prev, g, ppem, font.HintingNone); err == nil {
+				dot += k
+			}
+		}
+		glyphs = append(glyphs, glyph{g, dot})
+		if a, err := f.GlyphAdvance(&p.sbuf, g, ppem, font.HintingNone); err == nil {
+			dot += a
+		}
+		prev = g
+	}
+
+	ox, oy := p.px(x, y)
+	shift := -anchor * float64(dot) / 64
+	t := rotation * math.Pi / 180
+	ct, st := math.Cos(t), math.Sin(t)
+	var pa path
+	for _, g := range glyphs {
+		segs, err := f.LoadGlyph(&p.sbuf, g.index, ppem, nil)
+		if err != nil {
+			continue
+		}
+		gx := shift + float64(g.x)/64
+		for _, seg := range segs {
+			for j := 0; j < segpoints(seg); j++ {
+				dx, dy := gx+float64(seg.Args[j].X)/64, float64(seg.Args[j].Y)/64
+				seg.Args[j] = fpoint(ox+dx*ct+dy*st, oy-dx*st+dy*ct)
+			}
+			pa = append(pa, seg)
+		}
+	}
+	p.fill(pa, pngcolor(color, opacity))
+}
+
+// Text places left-aligned text at (x,y)
+func (p *PNG) Text(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, 0, font, 0, size, color, opacity)
+}
+
+// TextMid places centered text at (x,y)
+func (p *PNG) TextMid(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, 0.5, font, 0, size, color, opacity)
+}
+
+// TextEnd places right-aligned text at (x,y)
+func (p *PNG) TextEnd(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, 1, font, 0, size, color, opacity)
+}
+
+// TextRotate places text at (x,y), rotated counter-clockwise by degrees
+func (p *PNG) TextRotate(x, y float64, s, link, font string, rotation, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, 0, font, rotation, size, color, opacity)
+}
+
+// Line makes a line from (x1,y1) to (x2,y2) with thickness size
+func (p *PNG) Line(x1, y1, x2, y2, size float64, color string, opacity ...float64) {
+	sx1, sy1 := p.px(x1, y1)
+	sx2, sy2 := p.px(x2, y2)
+	l := math.Hypot(sx2-sx1, sy2-sy1)
+	if l == 0 {
+		return
+	}
+	// offset perpendicular to the line by half the thickness
+	w := math.Max(p.pw(size), 0.5) / 2
+	nx, ny := -(sy2-sy1)/l*w, (sx2-sx1)/l*w
+	var pa path
+	pa.moveTo(sx1+nx, sy1+ny)
+	pa.lineTo(sx2+nx, sy2+ny)
+	pa.lineTo(sx2-nx, sy2-ny)
+	pa.lineTo(sx1-nx, sy1-ny)
+	p.fill(pa, pngcolor(color, opacity))
+}
+
+// ellipse adds a closed elliptical outline centered at (cx,cy), traced
+// between the angles a1 and a2 (degrees, counter-clockwise)
+func (pa *path) ellipse(cx, cy, rx, ry, a1, a2 float64, start bool) {
+	n := int(math.Ceil(math.Abs(a2-a1)/2)) + 1
+	for i := 0; i <= n; i++ {
+		t := (a1 + (a2-a1)*float64(i)/float64(n)) * math.Pi / 180
+		x, y := cx+rx*math.Cos(t), cy-ry*math.Sin(t)
+		if i == 0 && start {
+			pa.moveTo(x, y)
+		} else {
+			pa.lineTo(x, y)
+		}
+	}
+}
+
+// Circle makes a circle centered at (x,y) with diameter w
+func (p *PNG) Circle(x, y, w float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	r := p.pw(w) / 2
+	var pa path
+	pa.ellipse(sx, sy, r, r, 0, 360, true)
+	p.fill(pa, pngcolor(color, opacity))
+}
+
+// Square makes a square centered at (x,y) with width w
+func (p *PNG) Square(x, y, w float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	sw := p.pw(w)
+	p.rect(sx, sy, sw, sw, pngcolor(color, opacity))
+}
+
+// Rect makes a rectangle centered at (x,y) with dimensions (w,h)
+func (p *PNG) Rect(x, y, w, h float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	p.rect(sx, sy, p.pw(w), (h/100)*p.Height, pngcolor(color, opacity))
+}
+
+// rect fills a rectangle centered at pixel coordinates (x,y)
+func (p *PNG) rect(x, y, w, h float64, c color.NRGBA) {
+	var pa path
+	pa.moveTo(x-w/2, y-h/2)
+	pa.lineTo(x+w/2, y-h/2)
+	pa.lineTo(x+w/2, y+h/2)
+	pa.lineTo(x-w/2, y+h/2)
+	p.fill(pa, c)
+}
+
+// Arc makes an arc centered at (x,y) with dimensions (w,h) and thickness size,
+// between the angles a1 and a2 (degrees, counter-clockwise)
+func (p *PNG) Arc(x, y, w, h, size, a1, a2 float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	rx, ry := p.pw(w)/2, p.pw(h)/2
+	t := p.pw(size) / 2
+	if math.Abs(a2-a1) > 360 {
+		a2 = a1 + math.Copysign(360, a2-a1)
+	}
+	// the outer edge one way, and the inner edge back
+	var pa path
+	pa.ellipse(sx, sy, rx+t, ry+t, a1, a2, true)
+	pa.ellipse(sx, sy, math.Max(rx-t, 0), math.Max(ry-t, 0), a2, a1, math.Abs(a2-a1) == 360)
+	p.fill(pa, pngcolor(color, opacity))
+}
+
+// Polygon makes a filled polygon with vertices in x and y
+func (p *PNG) Polygon(x, y []float64, color string, opacity ...float64) {
+	if len(x) < 3 || len(x) != len(y) {
+		return
+	}
+	var pa path
+	for i := range x {
+		sx, sy := p.px(x[i], y[i])
+		if i == 0 {
+			pa.moveTo(sx, sy)
+		} else {
+			pa.lineTo(sx, sy)
+		}
+	}
+	p.fill(pa, pngcolor(color, opacity))
+}
@@ -0,0 +1,205 @@
+package dchart
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reference is a reference line or shaded band
+type reference struct {
+	kind      string  // value, label or band
+	low, high float64 // the value, or the range of a band
+	at        string  // the data label of a label line
+	color     string
+	style     string // solid, dash or dot
+	label     string // escaped
+}
+
+// unlabeled returns references without their labels
+func unlabeled(refs []reference) []reference {
+	out := make([]reference, len(refs))
+	for i, r := range refs {
+		r.label = ""
+		out[i] = r
+	}
+	return out
+}
+
+// parsereferences parses the reference line (-hline) and reference specifications,
+// separated by semicolons: kind:position:color:style:label, where kind is
+// value (a line at a value), label (a line at a data label) or band (a shaded
+// range of values, low..high); style is solid, dash or dot. Empty or missing
+// fields are the defaults; the label is the rest of the specification.
+// For example "value:100:red:dash:Target; band:90..110:green::SLO range"
+func (s *Settings) parsereferences() ([]reference, error) {
+	var refs []reference
+	if hline := s.Attributes.HLine; len(hline) > 0 {
+		v, label, _ := strings.Cut(hline, ",")
+		hv, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q: hline must be value,label", hline)
+		}
+		refs = append(refs, reference{kind: "value", low: hv, high: hv, label: xmlesc(label)})
+	}
+	for _, spec := range strings.Split(s.Attributes.References, ";") {
+		spec = strings.TrimSpace(spec)
+		if len(spec) == 0 {
+			continue
+		}
+		f := strings.SplitN(spec, ":", 5)
+		for len(f) < 5 {
+			f = append(f, "")
+		}
+		r := reference{kind: f[0], color: f[2], style: f[3], label: xmlesc(f[4])}
+		var err error
+		switch r.kind {
+		case "value":
+			r.low, err = strconv.ParseFloat(f[1], 64)
+			r.high = r.low
+		case "band":
+			lo, hi, ok := strings.Cut(f[1], "..")
+			if !ok {
+				return nil, fmt.Errorf("%q: bands are low..high", spec)
+			}
+			if r.low, err = strconv.ParseFloat(lo, 64); err == nil {
+				r.high, err = strconv.ParseFloat(hi, 64)
+			}
+		case "label":
+			r.at = f[1]
+		default:
+			return nil, fmt.Errorf("%q: references are value, label or band", spec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%q: bad reference position", spec)
+		}
+		switch r.style {
+		case "", "solid", "dash", "dot":
+		default:
+			return nil, fmt.Errorf("%q: reference styles are solid, dash or dot", spec)
+		}
+		refs = append(refs, r)
+	}
+	return refs, nil
+}
+
+// styledline makes a solid, dashed or dotted line
+func styledline(deck Renderer, x1, y1, x2, y2, lw float64, style, color string, opacity float64) {
+	var dash, gap float64
+	switch style {
+	case "dash":
+		dash, gap = 1, 0.5
+	case "dot":
+		dash, gap = 0.2, 0.4
+	default:
+		deck.Line(x1, y1, x2, y2, lw, color, opacity)
+		return
+	}
+	length := math.Hypot(x2-x1, y2-y1)
+	if length == 0 {
+		return
+	}
+	dx, dy := (x2-x1)/length, (y2-y1)/length
+	for d := 0.0; d < length; d += dash + gap {
+		e := math.Min(d+dash, length)
+		deck.Line(x1+dx*d, y1+dy*d, x1+dx*e, y1+dy*e, lw, color, opacity)
+	}
+}
+
+// labelposition returns a function giving the position of a data label
+// along the label axis: the position of the first item with the label,
+// or for times, in proportion to the time along the axis from low to high
+func (s *Settings) labelposition(data []ChartData, pos []float64, times []time.Time, low, high float64) func(string) (float64, bool) {
+	return func(label string) (float64, bool) {
+		for i, d := range data {
+			if d.Label == label {
+				return pos[i], true
+			}
+		}
+		if len(times) == 0 {
+			return 0, false
+		}
+		layout := s.Attributes.TimeFormat
+		if len(layout) == 0 {
+			layout = Defaulttimefmt
+		}
+		t, err := time.Parse(layout, label)
+		if err != nil {
+			return 0, false
+		}
+		tmin, tmax := timespan(times)
+		return vmap(float64(t.Unix()), float64(tmin.Unix()), float64(tmax.Unix()), low, high), true
+	}
+}
+
+// drawreferences draws reference bands, then lines, across the chart area
+// (left, right, bottom, top), with labels. Values are placed on the
+// value axis with value, and data labels on the label axis with label (nil if
+// the chart has no label lines). The value axis is vertical, or horizontal if
+// across is set. Labels that are not in the data are reported as warnings.
+func (s *Settings) drawreferences(deck Renderer, refs []reference, value func(float64) float64, label func(string) (float64, bool), across bool, left, right, bottom, top float64) {
+	ts := s.Measures.TextSize
+	labelcolor := s.Attributes.LabelColor
+	lsize := ts * 0.75
+
+	// tag labels a line or band at p: horizontal ones at the right,
+	// vertical ones above the chart, or below horizontal bar charts
+	tag := func(r reference, p float64, vertical bool) {
+		switch {
+		case len(r.label) == 0:
+		case !vertical:
+			deck.Text(right+ts/2, p-ts/4, r.label, "serif", lsize, labelcolor)
+		case across:
+			deck.TextMid(p, bottom-ts*1.5, r.label, "serif", lsize, labelcolor)
+		default:
+			deck.TextMid(p, top+ts/2, r.label, "serif", lsize, labelcolor)
+		}
+	}
+	for _, r := range refs {
+		if r.kind != "band" {
+			continue
+		}
+		color := r.color
+		if len(color) == 0 {
+			color = s.Attributes.ValueColor
+		}
+		p1, p2 := value(r.low), value(r.high)
+		if across {
+			deck.Rect((p1+p2)/2, (bottom+top)/2, math.Abs(p2-p1), top-bottom, color, 20)
+		} else {
+			deck.Rect((left+right)/2, (p1+p2)/2, right-left, math.Abs(p2-p1), color, 20)
+		}
+		tag(r, (p1+p2)/2, across)
+	}
+	for _, r := range refs {
+		color, opacity := r.color, 100.0
+		if len(color) == 0 {
+			color, opacity = s.Attributes.ValueColor, 50
+		}
+		var p float64
+		switch r.kind {
+		case "value":
+			p = value(r.low)
+		case "label":
+			if label == nil {
+				continue
+			}
+			var ok bool
+			if p, ok = label(r.at); !ok {
+				s.Warnings = append(s.Warnings, fmt.Errorf("%q: no data label for the reference line", r.at))
+				continue
+			}
+		default:
+			continue
+		}
+		vertical := (r.kind == "value") == across
+		if vertical {
+			styledline(deck, p, bottom, p, top, 0.1, r.style, color, opacity)
+		} else {
+			styledline(deck, left, p, right, p, 0.1, r.style, color, opacity)
+		}
+		tag(r, p, vertical)
+	}
+}
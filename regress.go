@@ -0,0 +1,95 @@
+package dchart
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// t975 are the 97.5% quantiles of Student's t distribution, by degrees of freedom (1-30)
+var t975 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tquantile returns the 97.5% quantile of Student's t distribution with df degrees
+// of freedom, from the table, or the Cornish-Fisher expansion beyond it
+func tquantile(df int) float64 {
+	if df < 1 {
+		return math.NaN()
+	}
+	if df <= len(t975) {
+		return t975[df-1]
+	}
+	z, v := 1.959964, float64(df)
+	z3, z5 := z*z*z, z*z*z*z*z
+	return z + (z3+z)/(4*v) + (5*z5+16*z3+3*z)/(96*v*v)
+}
+
+// regression is a least-squares line fit: y = m*x + b
+type regression struct {
+	m, b  float64
+	r2    float64 // coefficient of determination
+	se    float64 // standard error of the residuals
+	xmean float64
+	sxx   float64 // sum of squared deviations of x
+	n     int
+}
+
+// regress fits a line to x, y. Fits need at least two distinct x values.
+func regress(x, y []float64) (regression, error) {
+	n := len(x)
+	r := regression{n: n}
+	if n < 2 {
+		return r, errors.New("regression: at least two points are needed")
+	}
+	r.xmean = mean(x)
+	for _, v := range x {
+		r.sxx += (v - r.xmean) * (v - r.xmean)
+	}
+	if r.sxx == 0 {
+		return r, errors.New("regression: the x values are all the same")
+	}
+	r.m, r.b = slope(x, y)
+	ymean := mean(y)
+	var ssres, sstot float64
+	for i := range x {
+		e := y[i] - r.at(x[i])
+		ssres += e * e
+		sstot += (y[i] - ymean) * (y[i] - ymean)
+	}
+	r.r2 = 1
+	if sstot > 0 {
+		r.r2 = 1 - ssres/sstot
+	}
+	if n > 2 {
+		r.se = math.Sqrt(ssres / float64(n-2))
+	}
+	return r, nil
+}
+
+// at returns the fitted value at x
+func (r regression) at(x float64) float64 {
+	return r.m*x + r.b
+}
+
+// interval returns the half width of the 95% confidence interval
+// of the fitted value at x (zero for fewer than three points)
+func (r regression) interval(x float64) float64 {
+	if r.n < 3 {
+		return 0
+	}
+	return tquantile(r.n-2) * r.se * math.Sqrt(1/float64(r.n)+(x-r.xmean)*(x-r.xmean)/r.sxx)
+}
+
+// String describes the fit: the equation, R² and the number of points
+func (r regression) String() string {
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', 4, 64) }
+	sign := "+"
+	if r.b < 0 {
+		sign = "−"
+	}
+	return "y = " + g(r.m) + "x " + sign + " " + g(math.Abs(r.b)) +
+		", R² = " + strconv.FormatFloat(r.r2, 'f', 3, 64) + ", n = " + strconv.Itoa(r.n)
+}
@@ -0,0 +1,106 @@
+package dchart
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSlope(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y []float64
+		m, b float64
+	}{
+		{"line", []float64{1, 2, 3}, []float64{2, 4, 6}, 2, 0},
+		{"falling", []float64{0, 2}, []float64{3, -1}, -2, 3},
+		{"no spread in x", []float64{2, 2}, []float64{1, 3}, 0, 2},
+		{"one point", []float64{5}, []float64{7}, 0, 7},
+		{"empty", nil, nil, 0, 0},
+	}
+	for _, test := range tests {
+		m, b := slope(test.x, test.y)
+		if !equalfloats([]float64{m, b}, []float64{test.m, test.b}) {
+			t.Errorf("%s: slope %v, %v, want %v, %v", test.name, m, b, test.m, test.b)
+		}
+	}
+}
+
+func TestRegress(t *testing.T) {
+	tests := []struct {
+		name         string
+		x, y         []float64
+		m, b, r2, se float64
+		at, interval float64 // the half width of the interval at x = at
+		desc         string
+		err          bool
+	}{
+		{
+			name: "exact", x: []float64{1, 2, 3}, y: []float64{3, 5, 7},
+			m: 2, b: 1, r2: 1, at: 2,
+			desc: "y = 2x + 1, R² = 1.000, n = 3",
+		},
+		{
+			name: "scattered", x: []float64{1, 2, 3, 4}, y: []float64{1, 3, 2, 4},
+			m: 0.8, b: 0.5, r2: 0.64, se: math.Sqrt(0.9), at: 2.5, interval: 4.303 * math.Sqrt(0.9) * 0.5,
+			desc: "y = 0.8x + 0.5, R² = 0.640, n = 4",
+		},
+		{
+			name: "two points", x: []float64{0, 1}, y: []float64{-1, 1},
+			m: 2, b: -1, r2: 1, at: 5,
+			desc: "y = 2x − 1, R² = 1.000, n = 2",
+		},
+		{
+			name: "level", x: []float64{1, 2, 3}, y: []float64{4, 4, 4},
+			m: 0, b: 4, r2: 1, at: 2,
+			desc: "y = 0x + 4, R² = 1.000, n = 3",
+		},
+		{name: "one point", x: []float64{1}, y: []float64{2}, err: true},
+		{name: "empty", err: true},
+		{name: "same x", x: []float64{2, 2, 2}, y: []float64{1, 2, 3}, err: true},
+	}
+	for _, test := range tests {
+		r, err := regress(test.x, test.y)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := []float64{r.m, r.b, r.r2, r.se, r.interval(test.at)}
+		want := []float64{test.m, test.b, test.r2, test.se, test.interval}
+		if !equalfloats(got, want) {
+			t.Errorf("%s: m, b, R², se, interval %v, want %v", test.name, got, want)
+		}
+		if r.String() != test.desc {
+			t.Errorf("%s: %q, want %q", test.name, r.String(), test.desc)
+		}
+	}
+}
+
+func TestTquantile(t *testing.T) {
+	tests := []struct {
+		df   int
+		want float64
+	}{
+		{1, 12.706},
+		{2, 4.303},
+		{30, 2.042},
+		{60, 2.000},
+		{120, 1.980},
+	}
+	for _, test := range tests {
+		if got := tquantile(test.df); math.Abs(got-test.want) > 0.001 {
+			t.Errorf("tquantile(%d) = %v, want %v", test.df, got, test.want)
+		}
+	}
+	if q := tquantile(0); !math.IsNaN(q) {
+		t.Errorf("tquantile(0) = %v, want NaN", q)
+	}
+	if q := tquantile(1e6); math.Abs(q-1.96) > 0.001 {
+		t.Errorf("tquantile(1e6) = %v, want 1.96", q)
+	}
+}
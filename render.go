@@ -0,0 +1,26 @@
+package dchart
+
+// Renderer draws the elements of charts, using percentage coordinates
+// with the origin at the lower left. *deckgen.DeckGen renders deck markup.
+type Renderer interface {
+	StartDeck()
+	EndDeck()
+	StartSlide(colors ...string)
+	EndSlide()
+	Text(x, y float64, s, font string, size float64, color string, opacity ...float64)
+	TextMid(x, y float64, s, font string, size float64, color string, opacity ...float64)
+	TextEnd(x, y float64, s, font string, size float64, color string, opacity ...float64)
+	TextRotate(x, y float64, s, link, font string, rotation, size float64, color string, opacity ...float64)
+	Line(x1, y1, x2, y2, size float64, color string, opacity ...float64)
+	Circle(x, y, w float64, color string, opacity ...float64)
+	Square(x, y, w float64, color string, opacity ...float64)
+	Rect(x, y, w, h float64, color string, opacity ...float64)
+	Arc(x, y, w, h, size, a1, a2 float64, color string, opacity ...float64)
+	Polygon(x, y []float64, color string, opacity ...float64)
+}
+
+// Tooltipper is implemented by renderers that attach a tooltip
+// (escaped text) to the next element drawn.
+type Tooltipper interface {
+	Tooltip(s string)
+}
@@ -0,0 +1,228 @@
+package dchart
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// SVG renders charts as a standalone SVG document. Each slide is a canvas
+// of the specified width and height; multiple slides are stacked vertically.
+type SVG struct {
+	Width, Height float64
+	dest          io.Writer
+	buf           bytes.Buffer
+	slides        [][]byte
+	tip           string
+}
+
+// NewSVG initializes the SVG renderer, writing to w with the canvas dimensions
+func NewSVG(w io.Writer, width, height float64) *SVG {
+	if width <= 0 || height <= 0 {
+		width, height = 792, 612
+	}
+	return &SVG{dest: w, Width: width, Height: height}
+}
+
+// svgfonts maps deck font names to SVG font families
+var svgfonts = map[string]string{
+	"sans":  "Helvetica,Arial,sans-serif",
+	"serif": "Times,Times New Roman,serif",
+	"mono":  "Courier,Courier New,monospace",
+}
+
+// svgfont returns the SVG font family for a deck font name
+func svgfont(font string) string {
+	if f, ok := svgfonts[font]; ok {
+		return f
+	}
+	return svgfonts["sans"]
+}
+
+// svgopacity returns the fill or stroke opacity (0-1) from an optional percentage
+func svgopacity(opacity []float64) float64 {
+	if len(opacity) == 0 || opacity[0] <= 0 {
+		return 1
+	}
+	return opacity[0] / 100
+}
+
+// svgcolor returns the SVG color for a deck color, black if unspecified
+func svgcolor(color string) string {
+	if len(color) == 0 {
+		return "black"
+	}
+	return color
+}
+
+// px converts a percentage coordinate to SVG pixels
+func (p *SVG) px(x, y float64) (float64, float64) {
+	return (x / 100) * p.Width, ((100 - y) / 100) * p.Height
+}
+
+// pw converts a percentage of the width to pixels
+func (p *SVG) pw(w float64) float64 {
+	return (w / 100) * p.Width
+}
+
+// StartDeck begins the document
+func (p *SVG) StartDeck() {
+	p.buf.Reset()
+	p.slides = nil
+}
+
+// EndDeck writes the document, with all slides
+func (p *SVG) EndDeck() {
+	if p.buf.Len() > 0 {
+		p.EndSlide()
+	}
+	n := len(p.slides)
+	if n == 0 {
+		n = 1
+	}
+	w, h := p.Width, p.Height
+	fmt.Fprintf(p.dest, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\">\n", w, h*float64(n), w, h*float64(n))
+	for i, s := range p.slides {
+		fmt.Fprintf(p.dest, "<g transform=\"translate(0,%.2f)\">\n", h*float64(i))
+		p.dest.Write(s)
+		fmt.Fprintln(p.dest, "</g>")
+	}
+	fmt.Fprintln(p.dest, "</svg>")
+}
+
+// StartSlide begins a slide, with an optional background color
+func (p *SVG) StartSlide(colors ...string) {
+	if p.buf.Len() > 0 {
+		p.EndSlide()
+	}
+	if len(colors) > 0 && len(colors[0]) > 0 {
+		fmt.Fprintf(&p.buf, "<rect x=\"0\" y=\"0\" width=\"%.2f\" height=\"%.2f\" fill=\"%s\"/>\n", p.Width, p.Height, colors[0])
+	}
+}
+
+// EndSlide ends a slide
+func (p *SVG) EndSlide() {
+	p.slides = append(p.slides, append([]byte(nil), p.buf.Bytes()...))
+	p.buf.Reset()
+}
+
+// Tooltip attaches a tooltip (escaped text) to the next element
+func (p *SVG) Tooltip(s string) {
+	p.tip = s
+}
+
+// element writes an element, grouped with the pending tooltip, if any
+func (p *SVG) element(format string, args ...interface{}) {
+	if len(p.tip) > 0 {
+		fmt.Fprintf(&p.buf, "<g class=\"tip\"><title>%s</title>", p.tip)
+		fmt.Fprintf(&p.buf, format, args...)
+		fmt.Fprintln(&p.buf, "</g>")
+		p.tip = ""
+		return
+	}
+	fmt.Fprintf(&p.buf, format, args...)
+	fmt.Fprintln(&p.buf)
+}
+
+// text places text with the specified anchor and rotation
+func (p *SVG) text(x, y float64, s, anchor, font string, rotation, size float64, color string, opacity []float64) {
+	sx, sy := p.px(x, y)
+	rot := ""
+	if rotation != 0 {
+		rot = fmt.Sprintf(" transform=\"rotate(%.2f %.2f %.2f)\"", -rotation, sx, sy)
+	}
+	fmt.Fprintf(&p.buf, "<text x=\"%.2f\" y=\"%.2f\" font-family=\"%s\" font-size=\"%.2f\" text-anchor=\"%s\" fill=\"%s\" fill-opacity=\"%.2f\"%s>%s</text>\n",
+		sx, sy, svgfont(font), p.pw(size), anchor, svgcolor(color), svgopacity(opacity), rot, s)
+}
+
+// Text places left-aligned text at (x,y)
+func (p *SVG) Text(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, "start", font, 0, size, color, opacity)
+}
+
+// TextMid places centered text at (x,y)
+func (p *SVG) TextMid(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, "middle", font, 0, size, color, opacity)
+}
+
+// TextEnd places right-aligned text at (x,y)
+func (p *SVG) TextEnd(x, y float64, s, font string, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, "end", font, 0, size, color, opacity)
+}
+
+// TextRotate places text at (x,y), rotated counter-clockwise by degrees
+func (p *SVG) TextRotate(x, y float64, s, link, font string, rotation, size float64, color string, opacity ...float64) {
+	p.text(x, y, s, "start", font, rotation, size, color, opacity)
+}
+
+// Line makes a line from (x1,y1) to (x2,y2) with thickness size
+func (p *SVG) Line(x1, y1, x2, y2, size float64, color string, opacity ...float64) {
+	sx1, sy1 := p.px(x1, y1)
+	sx2, sy2 := p.px(x2, y2)
+	p.element("<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\" stroke=\"%s\" stroke-width=\"%.2f\" stroke-opacity=\"%.2f\"/>",
+		sx1, sy1, sx2, sy2, svgcolor(color), p.pw(size), svgopacity(opacity))
+}
+
+// Circle makes a circle centered at (x,y) with diameter w
+func (p *SVG) Circle(x, y, w float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	p.element("<circle cx=\"%.2f\" cy=\"%.2f\" r=\"%.2f\" fill=\"%s\" fill-opacity=\"%.2f\"/>",
+		sx, sy, p.pw(w)/2, svgcolor(color), svgopacity(opacity))
+}
+
+// Square makes a square centered at (x,y) with width w
+func (p *SVG) Square(x, y, w float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	sw := p.pw(w)
+	p.element("<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" fill=\"%s\" fill-opacity=\"%.2f\"/>",
+		sx-sw/2, sy-sw/2, sw, sw, svgcolor(color), svgopacity(opacity))
+}
+
+// Rect makes a rectangle centered at (x,y) with dimensions (w,h)
+func (p *SVG) Rect(x, y, w, h float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	sw := p.pw(w)
+	sh := (h / 100) * p.Height
+	p.element("<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" fill=\"%s\" fill-opacity=\"%.2f\"/>",
+		sx-sw/2, sy-sh/2, sw, sh, svgcolor(color), svgopacity(opacity))
+}
+
+// Arc makes an arc centered at (x,y) with dimensions (w,h) and thickness size,
+// between the angles a1 and a2 (degrees, counter-clockwise)
+func (p *SVG) Arc(x, y, w, h, size, a1, a2 float64, color string, opacity ...float64) {
+	sx, sy := p.px(x, y)
+	rx, ry := p.pw(w)/2, p.pw(h)/2
+	if math.Abs(a2-a1) >= 360 {
+		p.element("<ellipse cx=\"%.2f\" cy=\"%.2f\" rx=\"%.2f\" ry=\"%.2f\" fill=\"none\" stroke=\"%s\" stroke-width=\"%.2f\" stroke-opacity=\"%.2f\"/>",
+			sx, sy, rx, ry, svgcolor(color), p.pw(size), svgopacity(opacity))
+		return
+	}
+	t1, t2 := a1*math.Pi/180, a2*math.Pi/180
+	x1, y1 := sx+rx*math.Cos(t1), sy-ry*math.Sin(t1)
+	x2, y2 := sx+rx*math.Cos(t2), sy-ry*math.Sin(t2)
+	large := 0
+	if math.Abs(a2-a1) > 180 {
+		large = 1
+	}
+	sweep := 0
+	if a2 < a1 {
+		sweep = 1
+	}
+	p.element("<path d=\"M%.2f,%.2f A%.2f,%.2f 0 %d %d %.2f,%.2f\" fill=\"none\" stroke=\"%s\" stroke-width=\"%.2f\" stroke-opacity=\"%.2f\"/>",
+		x1, y1, rx, ry, large, sweep, x2, y2, svgcolor(color), p.pw(size), svgopacity(opacity))
+}
+
+// Polygon makes a filled polygon with vertices in x and y
+func (p *SVG) Polygon(x, y []float64, color string, opacity ...float64) {
+	if len(x) < 3 || len(x) != len(y) {
+		return
+	}
+	var points strings.Builder
+	for i := range x {
+		sx, sy := p.px(x[i], y[i])
+		fmt.Fprintf(&points, "%.2f,%.2f ", sx, sy)
+	}
+	p.element("<polygon points=\"%s\" fill=\"%s\" fill-opacity=\"%.2f\"/>", points.String(), svgcolor(color), svgopacity(opacity))
+}
@@ -0,0 +1,236 @@
+package dchart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Otherlabel is the label of the sum of the data beyond the top N
+const Otherlabel = "Other"
+
+// parsefilter parses the filter expression low,high; either may be empty for no limit.
+// For example "10," keeps values of at least 10.
+func parsefilter(s string) (float64, float64, error) {
+	f := strings.Split(s, ",")
+	if len(f) != 2 {
+		return 0, 0, fmt.Errorf("%q: filter must be low,high", s)
+	}
+	limits := []float64{smallest, largest}
+	for i, v := range f {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%q: filter must be low,high", s)
+		}
+		limits[i] = x
+	}
+	return limits[0], limits[1], nil
+}
+
+// filterdata keeps the data with values from low to high
+func filterdata(data []ChartData, low, high float64) []ChartData {
+	var kept []ChartData
+	for _, d := range data {
+		if d.Value >= low && d.Value <= high {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// aggregate combines the data sharing a label (and the annotation, if bynote is set),
+// in order of first appearance, using the sum, mean, count, min or max of each series.
+// Each series is combined over the rows that have it. The first annotation is kept.
+func aggregate(data []ChartData, fn string, bynote bool) ([]ChartData, error) {
+	switch fn {
+	case "sum", "mean", "count", "min", "max":
+	default:
+		return nil, fmt.Errorf("%q: group must be sum, mean, count, min or max", fn)
+	}
+	var groups []ChartData
+	var counts [][]float64 // rows with each series, by group
+	index := map[string]int{}
+	for _, d := range data {
+		key := d.Label
+		if bynote {
+			key += "\x00" + d.Note
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ChartData{Label: d.Label, Note: d.Note, Values: append([]float64{}, d.Values...)})
+			counts = append(counts, make([]float64, len(d.Values)))
+			for k := range d.Values {
+				counts[i][k] = 1
+			}
+			continue
+		}
+		g := &groups[i]
+		for k, v := range d.Values {
+			if k >= len(g.Values) {
+				g.Values = append(g.Values, v)
+				counts[i] = append(counts[i], 1)
+				continue
+			}
+			switch fn {
+			case "min":
+				g.Values[k] = math.Min(g.Values[k], v)
+			case "max":
+				g.Values[k] = math.Max(g.Values[k], v)
+			default:
+				g.Values[k] += v
+			}
+			counts[i][k]++
+		}
+	}
+	for i := range groups {
+		g := &groups[i]
+		for k := range g.Values {
+			switch fn {
+			case "mean":
+				g.Values[k] /= counts[i][k]
+			case "count":
+				g.Values[k] = counts[i][k]
+			}
+		}
+		g.Value = g.Values[0]
+	}
+	return groups, nil
+}
+
+// sortdata sorts data by label or value (the first series), ascending,
+// or descending with ",desc". For example "value,desc".
+// Labels that are numbers are sorted numerically.
+func sortdata(data []ChartData, spec string) error {
+	key, order, _ := strings.Cut(spec, ",")
+	key, order = strings.TrimSpace(key), strings.TrimSpace(order)
+	if order != "" && order != "asc" && order != "desc" {
+		return fmt.Errorf("%q: sort must be label or value, optionally with asc or desc", spec)
+	}
+	var less func(a, b ChartData) bool
+	switch key {
+	case "value":
+		less = func(a, b ChartData) bool { return a.Value < b.Value }
+	case "label":
+		less = func(a, b ChartData) bool {
+			x, xerr := strconv.ParseFloat(a.Label, 64)
+			y, yerr := strconv.ParseFloat(b.Label, 64)
+			if xerr == nil && yerr == nil {
+				return x < y
+			}
+			return a.Label < b.Label
+		}
+	default:
+		return fmt.Errorf("%q: sort must be label or value, optionally with asc or desc", spec)
+	}
+	if order == "desc" {
+		sort.SliceStable(data, func(i, j int) bool { return less(data[j], data[i]) })
+	} else {
+		sort.SliceStable(data, func(i, j int) bool { return less(data[i], data[j]) })
+	}
+	return nil
+}
+
+// topdata keeps the n data with the largest values (in their order),
+// adding the sum of the rest as "Other"
+func topdata(data []ChartData, n int) []ChartData {
+	if n <= 0 || len(data) <= n {
+		return data
+	}
+	order := make([]int, len(data))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return data[order[a]].Value > data[order[b]].Value })
+	top := make([]bool, len(data))
+	for _, i := range order[:n] {
+		top[i] = true
+	}
+	var kept []ChartData
+	other := ChartData{Label: Otherlabel}
+	for i, d := range data {
+		if top[i] {
+			kept = append(kept, d)
+			continue
+		}
+		for len(other.Values) < len(d.Values) {
+			other.Values = append(other.Values, 0)
+		}
+		for k, v := range d.Values {
+			other.Values[k] += v
+		}
+	}
+	other.Value = other.Values[0]
+	return append(kept, other)
+}
+
+// cumulate replaces the values of each series with their running sums
+func cumulate(data []ChartData) {
+	var sums []float64
+	for i := range data {
+		d := &data[i]
+		for len(sums) < len(d.Values) {
+			sums = append(sums, 0)
+		}
+		for k, v := range d.Values {
+			sums[k] += v
+			d.Values[k] = sums[k]
+		}
+		d.Value = d.Values[0]
+	}
+}
+
+// transform applies the data transformations of the settings, in order:
+// filter, group, sort, top N (with the rest as "Other"), and cumulative sums.
+// The extrema are recomputed. Triples (heatmaps and grouped treemaps) are grouped
+// by both keys, the label and annotation, and are not limited to the top N.
+func (s *Settings) transform(ds Dataset) (Dataset, error) {
+	a := s.Attributes
+	if len(a.Filter) == 0 && len(a.Aggregate) == 0 && len(a.SortBy) == 0 && s.Measures.TopN <= 0 && !s.Flags.CumulativeSum {
+		return ds, nil
+	}
+	data := make([]ChartData, len(ds.Data))
+	for i, d := range ds.Data {
+		d.Values = append([]float64{}, d.Values...)
+		data[i] = d
+	}
+	var err error
+	if len(a.Filter) > 0 {
+		low, high, err := parsefilter(a.Filter)
+		if err != nil {
+			return ds, err
+		}
+		data = filterdata(data, low, high)
+	}
+	if len(a.Aggregate) > 0 {
+		if data, err = aggregate(data, a.Aggregate, s.triples()); err != nil {
+			return ds, err
+		}
+	}
+	if len(a.SortBy) > 0 {
+		if err := sortdata(data, a.SortBy); err != nil {
+			return ds, err
+		}
+	}
+	if n := s.Measures.TopN; n > 0 && s.triples() {
+		s.Warnings = append(s.Warnings, fmt.Errorf("top %d is not used with heatmaps or grouped treemaps", n))
+	} else {
+		data = topdata(data, n)
+	}
+	if s.Flags.CumulativeSum {
+		cumulate(data)
+	}
+	ds.Data = data
+	ds.Min, ds.Max = largest, smallest
+	for _, d := range data {
+		ds.Min, ds.Max = minmax(d.Values, ds.Min, ds.Max)
+	}
+	return ds, nil
+}
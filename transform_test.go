@@ -0,0 +1,197 @@
+package dchart
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rows makes data from label/note:value1,value2 specifications (the note is optional)
+func rows(specs ...string) []ChartData {
+	var data []ChartData
+	for _, spec := range specs {
+		label, values, _ := strings.Cut(spec, ":")
+		var d ChartData
+		d.Label, d.Note, _ = strings.Cut(label, "/")
+		for _, v := range strings.Split(values, ",") {
+			x, _ := strconv.ParseFloat(v, 64)
+			d.Values = append(d.Values, x)
+		}
+		d.Value = d.Values[0]
+		data = append(data, d)
+	}
+	return data
+}
+
+// checkrows compares data with the expected labels and values
+func checkrows(t *testing.T, name string, got, want []ChartData) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: %d rows %v, want %d %v", name, len(got), got, len(want), want)
+		return
+	}
+	for i := range got {
+		g, w := got[i], want[i]
+		if g.Label != w.Label || g.Note != w.Note || !equalfloats(g.Values, w.Values) || g.Value != g.Values[0] {
+			t.Errorf("%s: row %d is %s/%s %v, want %s/%s %v", name, i, g.Label, g.Note, g.Values, w.Label, w.Note, w.Values)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []ChartData
+		err  bool
+	}{
+		{spec: "2,4", want: rows("b:2", "c:4")},
+		{spec: "3,", want: rows("c:4", "d:6")},
+		{spec: ",1", want: rows("a:-1")},
+		{spec: "-1,-1", want: rows("a:-1")},
+		{spec: "7,", want: nil},
+		{spec: "3", err: true},
+		{spec: "a,b", err: true},
+	}
+	for _, test := range tests {
+		low, high, err := parsefilter(test.spec)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: no error", test.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+			continue
+		}
+		checkrows(t, test.spec, filterdata(rows("a:-1", "b:2", "c:4", "d:6"), low, high), test.want)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	data := rows("a:1,10", "b:2", "a:3", "b:4,20", "a:8,30")
+	tests := []struct {
+		fn   string
+		want []ChartData
+	}{
+		{"sum", rows("a:12,40", "b:6,20")},
+		{"mean", rows("a:4,20", "b:3,20")},
+		{"count", rows("a:3,2", "b:2,1")},
+		{"min", rows("a:1,10", "b:2,20")},
+		{"max", rows("a:8,30", "b:4,20")},
+	}
+	for _, test := range tests {
+		got, err := aggregate(data, test.fn, false)
+		if err != nil {
+			t.Errorf("%s: %v", test.fn, err)
+			continue
+		}
+		checkrows(t, test.fn, got, test.want)
+	}
+	if _, err := aggregate(data, "median", false); err == nil {
+		t.Errorf("median: no error")
+	}
+
+	// triples are grouped by both keys
+	cells := rows("r1/c1:1", "r1/c2:2", "r2/c1:3", "r1/c1:4")
+	got, err := aggregate(cells, "sum", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkrows(t, "triples", got, rows("r1/c1:5", "r1/c2:2", "r2/c1:3"))
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []ChartData
+		err  bool
+	}{
+		{spec: "value", want: rows("x:-1", "10:2", "b:2", "9:5")},
+		{spec: "value,desc", want: rows("9:5", "10:2", "b:2", "x:-1")},
+		{spec: "label", want: rows("9:5", "10:2", "b:2", "x:-1")},
+		{spec: "label,asc", want: rows("9:5", "10:2", "b:2", "x:-1")},
+		{spec: "label,desc", want: rows("x:-1", "b:2", "10:2", "9:5")},
+		{spec: "size", err: true},
+		{spec: "value,up", err: true},
+	}
+	for _, test := range tests {
+		data := rows("10:2", "x:-1", "9:5", "b:2")
+		err := sortdata(data, test.spec)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: no error", test.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+			continue
+		}
+		checkrows(t, test.spec, data, test.want)
+	}
+
+	// numeric labels sort as numbers
+	data := rows("10:1", "9:2", "100:3")
+	if err := sortdata(data, "label"); err != nil {
+		t.Fatal(err)
+	}
+	checkrows(t, "numeric labels", data, rows("9:2", "10:1", "100:3"))
+}
+
+func TestTopdata(t *testing.T) {
+	data := rows("a:1,1", "b:5,2", "c:3", "d:4,4")
+	tests := []struct {
+		n    int
+		want []ChartData
+	}{
+		{0, data},
+		{4, data},
+		{2, rows("b:5,2", "d:4,4", "Other:4,1")},
+		{1, rows("b:5,2", "Other:8,5")},
+	}
+	for _, test := range tests {
+		checkrows(t, "top "+strconv.Itoa(test.n), topdata(data, test.n), test.want)
+	}
+}
+
+func TestCumulate(t *testing.T) {
+	data := rows("a:1,10", "b:2", "c:-4,5")
+	cumulate(data)
+	checkrows(t, "cumulate", data, rows("a:1,10", "b:3", "c:-1,15"))
+}
+
+func TestTransform(t *testing.T) {
+	s := NewChart("bar", 0, 0, 0, 0)
+	s.Attributes.Filter = "0,"
+	s.Attributes.Aggregate = "sum"
+	s.Attributes.SortBy = "value,desc"
+	s.Measures.TopN = 2
+	s.Flags.CumulativeSum = true
+	in := NewDataset(rows("a:1", "b:2", "a:3", "c:-5", "d:1", "e:2"), "")
+	ds, err := s.transform(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// filtered: a 1, b 2, a 3, d 1, e 2; grouped: a 4, b 2, d 1, e 2;
+	// sorted: a 4, b 2, e 2, d 1; top 2: a 4, b 2, Other 3; cumulative
+	checkrows(t, "transform", ds.Data, rows("a:4", "b:6", "Other:9"))
+	if ds.Min != 4 || ds.Max != 9 {
+		t.Errorf("transform: extrema %v, %v, want 4, 9", ds.Min, ds.Max)
+	}
+	// the input is unchanged
+	checkrows(t, "input", in.Data, rows("a:1", "b:2", "a:3", "c:-5", "d:1", "e:2"))
+
+	// heatmap cells are grouped by row and column, and not limited to the top N
+	s = NewChart("heatmap", 0, 0, 0, 0)
+	s.Attributes.Aggregate = "sum"
+	s.Measures.TopN = 1
+	ds, err = s.transform(NewDataset(rows("r1/c1:1", "r2/c1:2", "r1/c1:3", "r1/c2:4"), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkrows(t, "heatmap", ds.Data, rows("r1/c1:4", "r2/c1:2", "r1/c2:4"))
+	if len(s.Warnings) != 1 {
+		t.Errorf("heatmap: warnings %v, want one for the top N", s.Warnings)
+	}
+}
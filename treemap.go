@@ -0,0 +1,242 @@
+package dchart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// tmrect is a treemap rectangle: the lower left corner, width and height
+type tmrect struct {
+	x, y, w, h float64
+}
+
+// worst returns the largest aspect ratio of the areas of a row laid along a side
+func worst(areas []float64, side float64) float64 {
+	sum, ratio := 0.0, 0.0
+	for _, a := range areas {
+		sum += a
+	}
+	for _, a := range areas {
+		ratio = math.Max(ratio, math.Max(side*side*a/(sum*sum), sum*sum/(side*side*a)))
+	}
+	return ratio
+}
+
+// squarify divides a rectangle into rectangles with areas in proportion
+// to the values (sorted largest first), keeping them as square as possible,
+// using the squarified treemap algorithm of Bruls, Huizing and van Wijk.
+// Rows are laid along the shorter side of the remaining space.
+func squarify(values []float64, r tmrect) []tmrect {
+	out := make([]tmrect, 0, len(values))
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	if total <= 0 {
+		return append(out, make([]tmrect, len(values))...)
+	}
+	areas := make([]float64, len(values))
+	for i, v := range values {
+		areas[i] = v * r.w * r.h / total
+	}
+	for i := 0; i < len(areas); {
+		side := math.Min(r.w, r.h)
+		j := i + 1
+		for j < len(areas) && worst(areas[i:j+1], side) <= worst(areas[i:j], side) {
+			j++
+		}
+		rowsum := 0.0
+		for _, a := range areas[i:j] {
+			rowsum += a
+		}
+		if rowsum <= 0 {
+			// the rest are zero: empty rectangles at the corner
+			for range areas[i:] {
+				out = append(out, tmrect{r.x, r.y, 0, 0})
+			}
+			break
+		}
+		if r.w >= r.h {
+			// a column at the left
+			cw := rowsum / r.h
+			y := r.y + r.h
+			for _, a := range areas[i:j] {
+				ah := a / cw
+				out = append(out, tmrect{r.x, y - ah, cw, ah})
+				y -= ah
+			}
+			r.x += cw
+			r.w -= cw
+		} else {
+			// a row at the top
+			rh := rowsum / r.w
+			x := r.x
+			for _, a := range areas[i:j] {
+				aw := a / rh
+				out = append(out, tmrect{x, r.y + r.h - rh, aw, rh})
+				x += aw
+			}
+			r.h -= rh
+		}
+		i = j
+	}
+	return out
+}
+
+// tmlayout places items with values in a rectangle, in the order of the items
+func tmlayout(values []float64, r tmrect) []tmrect {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] > values[order[b]] })
+	sorted := make([]float64, len(values))
+	for i, k := range order {
+		sorted[i] = math.Max(values[k], 0)
+	}
+	rects := make([]tmrect, len(values))
+	for i, rect := range squarify(sorted, r) {
+		rects[order[i]] = rect
+	}
+	return rects
+}
+
+// treemap makes a treemap: rectangles with areas proportional to the data,
+// within the chart boundary, colored like proportional maps.
+// Grouped data (group, label, value) is laid out in a rectangle for each group,
+// labeled with the group name; data items are colored by group.
+// Labels, percentages and values are shown where they fit.
+func (s *Settings) treemap(deck Renderer, data []ChartData, title string) {
+	top := s.Measures.Top
+	bottom := s.Measures.Bottom
+	left := s.Measures.Left
+	right := s.Measures.Right
+	ts := s.Measures.TextSize
+	datacolor := s.Attributes.DataColor
+	df := s.Attributes.DataFmt
+	if left < 0 {
+		left = 10.0
+	}
+	// layout is in units of the canvas width, so that rectangles are square on the canvas
+	aspect := s.aspect()
+	bounds := tmrect{left, bottom / aspect, right - left, (top - bottom) / aspect}
+	const gap = 0.2
+	lsize := ts * 0.75
+	pad := lsize * 0.4
+
+	if len(title) > 0 && s.Flags.ShowTitle {
+		deck.TextMid(left+(right-left)/2, top+(ts*s.Measures.LineSpacing*1.5), title, "sans", ts*1.5, Titlecolor)
+	}
+	sum := datasum(data)
+	pcts := pct(data)
+
+	// fits reports whether n lines of text of a width fit in a rectangle
+	fits := func(r tmrect, width float64, n int) bool {
+		return width+pad*2 <= r.w-gap && float64(n)*lsize*1.5+pad <= r.h-gap
+	}
+	textwidth := func(s string, size float64) float64 {
+		return float64(len([]rune(s))) * size * 0.6
+	}
+
+	// the cell of each item, and the color of its group
+	cells := make([]tmrect, len(data))
+	colors := func(i int) (string, float64) {
+		return stdcolor(i, data[i].Note, datacolor, pcts[i], s.Flags.SolidPMap)
+	}
+	var groups []string
+	var grects []tmrect
+	if s.Flags.Grouped {
+		// items are labeled by group and annotated with their label
+		index := map[string]int{}
+		var gsum []float64
+		members := [][]int{}
+		for i, d := range data {
+			g, ok := index[d.Label]
+			if !ok {
+				g = len(groups)
+				index[d.Label] = g
+				groups = append(groups, d.Label)
+				gsum = append(gsum, 0)
+				members = append(members, nil)
+			}
+			gsum[g] += math.Max(d.Value, 0)
+			members[g] = append(members[g], i)
+		}
+		grects = tmlayout(gsum, bounds)
+		for g, gr := range grects {
+			// leave room for the group label
+			inner := tmrect{gr.x + gap, gr.y + gap, gr.w - gap*2, gr.h - gap*2}
+			if fits(gr, textwidth(groups[g], lsize), 2) {
+				inner.h -= lsize * 1.5
+			}
+			values := make([]float64, len(members[g]))
+			for k, i := range members[g] {
+				values[k] = data[i].Value
+			}
+			for k, r := range tmlayout(values, inner) {
+				cells[members[g][k]] = r
+			}
+		}
+		colors = func(i int) (string, float64) {
+			return stdcolor(index[data[i].Label], "", datacolor, pcts[i], s.Flags.SolidPMap)
+		}
+	} else {
+		values := make([]float64, len(data))
+		for i, d := range data {
+			values[i] = d.Value
+		}
+		cells = tmlayout(values, bounds)
+	}
+
+	for g, gr := range grects {
+		if fits(gr, textwidth(groups[g], lsize), 2) {
+			deck.Text(gr.x+pad, (gr.y+gr.h)*aspect-lsize*1.2*aspect, groups[g], "sans", lsize, s.Attributes.LabelColor)
+		}
+	}
+	for i, d := range data {
+		r := cells[i]
+		if r.w <= gap || r.h <= gap {
+			continue
+		}
+		color, op := colors(i)
+		textcolor := "black"
+		if op == 100 {
+			textcolor = "white"
+		}
+		label := d.Label
+		if s.Flags.Grouped {
+			label = d.Note
+		}
+		s.tooltip(deck, label, d.Value, sum, "")
+		deck.Rect(r.x+r.w/2, (r.y+r.h/2)*aspect, r.w-gap, (r.h-gap)*aspect, color, op)
+
+		// the label, percentage and value, from the top left, as they fit
+		lines := []string{label, fmt.Sprintf(df+"%%", pcts[i])}
+		if s.Flags.ShowValues {
+			lines = append(lines, dformat(df, d.Value))
+		}
+		ty := r.y + r.h - pad
+		for n, line := range lines {
+			if !fits(r, textwidth(line, lsize), n+1) {
+				break
+			}
+			ty -= lsize * 1.2
+			deck.Text(r.x+pad, ty*aspect, line, "sans", lsize, textcolor)
+			ty -= lsize * 0.3
+		}
+	}
+
+	if s.Flags.ShowLegend {
+		var items []legenditem
+		if s.Flags.Grouped {
+			for g, name := range groups {
+				c, _ := stdcolor(g, "", datacolor, 100, true)
+				items = append(items, legenditem{label: name, color: c, opacity: 100})
+			}
+		} else {
+			items = datalegenditems(data, colors)
+		}
+		s.chartlegend(deck, items, barswatch, left, right, top, bottom)
+	}
+}
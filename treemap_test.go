@@ -0,0 +1,83 @@
+package dchart
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTmlayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		r      tmrect
+		want   []tmrect
+	}{
+		{name: "one", values: []float64{5}, r: tmrect{0, 0, 10, 4}, want: []tmrect{{0, 0, 10, 4}}},
+		{name: "two equal", values: []float64{1, 1}, r: tmrect{0, 0, 4, 2}, want: []tmrect{{0, 0, 2, 2}, {2, 0, 2, 2}}},
+		{name: "item order", values: []float64{1, 3}, r: tmrect{0, 0, 4, 1}, want: []tmrect{{3, 0, 1, 1}, {0, 0, 3, 1}}},
+		{name: "tall", values: []float64{1, 1}, r: tmrect{0, 0, 2, 4}, want: []tmrect{{0, 2, 2, 2}, {0, 0, 2, 2}}},
+		{name: "zero and negative", values: []float64{2, 0, -1}, r: tmrect{0, 0, 2, 2}, want: []tmrect{{0, 0, 2, 2}, {2, 0, 0, 0}, {2, 0, 0, 0}}},
+		{name: "all zero", values: []float64{0, 0}, r: tmrect{1, 1, 2, 2}, want: []tmrect{{}, {}}},
+		{name: "empty", r: tmrect{0, 0, 1, 1}, want: []tmrect{}},
+	}
+	for _, test := range tests {
+		got := tmlayout(test.values, test.r)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			g, w := got[i], test.want[i]
+			if !equalfloats([]float64{g.x, g.y, g.w, g.h}, []float64{w.x, w.y, w.w, w.h}) {
+				t.Errorf("%s: rectangle %d is %v, want %v", test.name, i, g, w)
+			}
+		}
+	}
+}
+
+func TestSquarify(t *testing.T) {
+	// the example of Bruls, Huizing and van Wijk: areas in proportion, within the
+	// rectangle, without overlaps, and as square as the paper's layout
+	values := []float64{6, 6, 4, 3, 2, 2, 1}
+	r := tmrect{0, 0, 6, 4}
+	rects := squarify(values, r)
+	if len(rects) != len(values) {
+		t.Fatalf("%d rectangles, want %d", len(rects), len(values))
+	}
+	const eps = 1e-9
+	for i, a := range rects {
+		if math.Abs(a.w*a.h-values[i]) > eps {
+			t.Errorf("rectangle %d %v has area %v, want %v", i, a, a.w*a.h, values[i])
+		}
+		if a.x < r.x-eps || a.y < r.y-eps || a.x+a.w > r.x+r.w+eps || a.y+a.h > r.y+r.h+eps {
+			t.Errorf("rectangle %d %v is outside %v", i, a, r)
+		}
+		if ratio := math.Max(a.w/a.h, a.h/a.w); ratio > 3 {
+			t.Errorf("rectangle %d %v has aspect ratio %v", i, a, ratio)
+		}
+		for j, b := range rects[:i] {
+			if a.x+eps < b.x+b.w && b.x+eps < a.x+a.w && a.y+eps < b.y+b.h && b.y+eps < a.y+a.h {
+				t.Errorf("rectangles %d %v and %d %v overlap", j, b, i, a)
+			}
+		}
+	}
+}
+
+func TestWorst(t *testing.T) {
+	tests := []struct {
+		areas []float64
+		side  float64
+		want  float64
+	}{
+		{[]float64{4}, 2, 1},
+		{[]float64{4, 4}, 2, 4},
+		{[]float64{6}, 4, 8.0 / 3},
+		{[]float64{6, 6}, 4, 1.5},
+		{[]float64{6, 6, 4}, 4, 4},
+	}
+	for _, test := range tests {
+		if got := worst(test.areas, test.side); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%v along %v: %v, want %v", test.areas, test.side, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package dchart
+
+import "strings"
+
+// kinds of waterfall bars
+const (
+	wftotal = iota
+	wfup
+	wfdown
+)
+
+// istotal reports whether an annotation marks a subtotal or total row
+func istotal(note string) bool {
+	switch strings.ToLower(strings.TrimSpace(note)) {
+	case "total", "subtotal":
+		return true
+	}
+	return false
+}
+
+// waterfall returns the extents of the bars of a waterfall (bridge) chart:
+// each bar begins at start and ends at end, the running total after the bar.
+// The first item is the starting value, and items annotated "total" or "subtotal"
+// show the running total (their values are not used); the other values are
+// changes, floating from the running total.
+func waterfall(data []ChartData) ([]float64, []float64, []int) {
+	start := make([]float64, len(data))
+	end := make([]float64, len(data))
+	kind := make([]int, len(data))
+	total := 0.0
+	for i, d := range data {
+		switch {
+		case i == 0:
+			total = d.Value
+			end[i] = total
+		case istotal(d.Note):
+			end[i] = total
+		default:
+			start[i] = total
+			total += d.Value
+			end[i] = total
+			kind[i] = wfup
+			if d.Value < 0 {
+				kind[i] = wfdown
+			}
+		}
+	}
+	return start, end, kind
+}
+
+// waterfallcolor returns the color of a waterfall bar:
+// the up color for increases, the down color for decreases, and the data color for totals
+func (s *Settings) waterfallcolor(kind int) string {
+	switch kind {
+	case wfup:
+		return s.Attributes.UpColor
+	case wfdown:
+		return s.Attributes.DownColor
+	}
+	return s.Attributes.DataColor
+}
+
+// waterfalllegenditems makes legend entries for the kinds of waterfall bars
+func (s *Settings) waterfalllegenditems() []legenditem {
+	return []legenditem{
+		{label: "increase", color: s.waterfallcolor(wfup), opacity: 100},
+		{label: "decrease", color: s.waterfallcolor(wfdown), opacity: 100},
+		{label: "total", color: s.waterfallcolor(wftotal), opacity: 100},
+	}
+}
@@ -0,0 +1,72 @@
+package dchart
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWaterfall(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []ChartData
+		start, end []float64
+		kind       []int
+	}{
+		{
+			name: "changes and totals",
+			data: []ChartData{
+				NewChartData("start", "", 100),
+				NewChartData("sales", "", 30),
+				NewChartData("costs", "", -50),
+				NewChartData("q1", "Subtotal", 999),
+				NewChartData("tax", "", -10),
+				NewChartData("end", "total", 0),
+			},
+			start: []float64{0, 100, 130, 0, 80, 0},
+			end:   []float64{100, 130, 80, 80, 70, 70},
+			kind:  []int{wftotal, wfup, wfdown, wftotal, wfdown, wftotal},
+		},
+		{
+			name:  "negative start",
+			data:  []ChartData{NewChartData("a", "", -5), NewChartData("b", "", 2)},
+			start: []float64{0, -5},
+			end:   []float64{-5, -3},
+			kind:  []int{wftotal, wfup},
+		},
+		{name: "empty"},
+	}
+	for _, test := range tests {
+		start, end, kind := waterfall(test.data)
+		if !equalfloats(start, test.start) || !equalfloats(end, test.end) {
+			t.Errorf("%s: bars %v to %v, want %v to %v", test.name, start, end, test.start, test.end)
+		}
+		if len(kind) != len(test.kind) {
+			t.Errorf("%s: kinds %v, want %v", test.name, kind, test.kind)
+			continue
+		}
+		for i := range kind {
+			if kind[i] != test.kind[i] {
+				t.Errorf("%s: kinds %v, want %v", test.name, kind, test.kind)
+				break
+			}
+		}
+	}
+}
+
+func TestWaterfallSeries(t *testing.T) {
+	data := []ChartData{NewChartData("a", "", 5, 3), NewChartData("b", "", 2, 1)}
+	for _, strict := range []bool{false, true} {
+		s := NewChart("waterfall", 0, 0, 0, 0)
+		s.Flags.StrictData = strict
+		var buf bytes.Buffer
+		err := s.Render(NewDecksh(&buf), data, "")
+		switch {
+		case strict && err == nil:
+			t.Errorf("strict: no error for two series")
+		case !strict && err != nil:
+			t.Errorf("lenient: %v", err)
+		case !strict && len(s.Warnings) != 1:
+			t.Errorf("lenient: warnings %v, want one", s.Warnings)
+		}
+	}
+}
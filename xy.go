@@ -0,0 +1,174 @@
+package dchart
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// xypoints returns the x, y and size of each data item.
+// Items with two or more values use the first two as x and y, and the third, if present, as the size;
+// otherwise the label is the x value.
+func xypoints(data []ChartData) ([]float64, []float64, []float64, error) {
+	l := len(data)
+	x := make([]float64, l)
+	y := make([]float64, l)
+	size := make([]float64, l)
+	for i, d := range data {
+		if len(d.Values) >= 2 {
+			x[i], y[i] = d.Values[0], d.Values[1]
+			if len(d.Values) > 2 {
+				size[i] = d.Values[2]
+			}
+			continue
+		}
+		v, err := strconv.ParseFloat(d.Label, 64)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("x value %q is not a number", d.Label)
+		}
+		x[i], y[i] = v, d.Value
+	}
+	return x, y, size, nil
+}
+
+// xaxis constructs x axis labels and vertical gridlines, analogous to yaxis
+func (s *Settings) xaxis(deck Renderer, y, dmin, dmax float64) {
+	var axismin, axismax, step float64
+	left := s.Measures.Left
+	if left < 0 {
+		left = 10.0
+	}
+	if s.Attributes.XAxisR == "" {
+		axismin, axismax, step = cyrange(dmin, dmax, s.nticks(s.Measures.Right-left))
+	} else {
+		axismin, axismax, step = yrange(s.Attributes.XAxisR)
+	}
+	if step <= 0 {
+		return
+	}
+	for _, x := range axisvalues(axismin, axismax, step, dmin, dmax) {
+		xp := vmap(x, dmin, dmax, left, s.Measures.Right)
+		deck.TextMid(xp, y, s.axislabel(x, step), "sans", s.Measures.TextSize*0.75, s.Attributes.LabelColor)
+		if s.Flags.ShowGrid {
+			deck.Line(xp, s.Measures.Bottom, xp, s.Measures.Top, 0.1, "lightgray")
+		}
+	}
+}
+
+// xychart makes a scatter plot of y against x, with optional sizes (bubbles).
+// The annotation, if present, is the color of the point.
+func (s *Settings) xychart(deck Renderer, ds Dataset) error {
+	data, title := ds.Data, ds.Title
+	x, y, size, err := xypoints(data)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	left := s.Measures.Left
+	right := s.Measures.Right
+	top := s.Measures.Top
+	bottom := s.Measures.Bottom
+	ts := s.Measures.TextSize
+	linespacing := ts * s.Measures.LineSpacing
+	spacing := ts * 1.5
+	if left < 0 {
+		left = 10.0
+	}
+
+	xmin, xmax := minmax(x, largest, smallest)
+	mindata, maxdata := minmax(y, largest, smallest)
+	_, maxsize := minmax(size, largest, smallest)
+	xmin, xmax = expandrange(xmin, xmax)
+	if !s.Flags.DataMinimum {
+		mindata = 0
+	}
+	if s.Measures.UserMin >= 0 {
+		mindata = s.Measures.UserMin
+	}
+	if s.Measures.UserMax >= 0 && s.Measures.UserMax > mindata {
+		maxdata = s.Measures.UserMax
+	}
+	if s.Flags.LogScale {
+		ydata := make([]ChartData, len(data))
+		for i, d := range data {
+			ydata[i] = ChartData{Label: d.Label, Value: y[i], Values: []float64{y[i]}}
+		}
+		mindata, maxdata, err = s.logrange(ydata, mindata, maxdata)
+		if err != nil {
+			return err
+		}
+	}
+	mindata, maxdata = expandrange(mindata, maxdata)
+	// explicit x ranges set the x extrema
+	if s.Attributes.XAxisR != "" {
+		if amin, amax, step := yrange(s.Attributes.XAxisR); step > 0 {
+			xmin, xmax = amin, amax
+		}
+	}
+
+	if s.Flags.FullDeck {
+		deck.StartSlide(s.Attributes.BackgroundColor)
+	}
+	if s.Flags.ShowFrame {
+		fw := right - left
+		fh := top - bottom
+		deck.Rect(left+(fw/2), bottom+(fh/2), fw, fh, s.Attributes.FrameColor, 5)
+	}
+	if len(s.Attributes.ChartTitle) > 0 {
+		title = xmlesc(s.Attributes.ChartTitle)
+	}
+	if len(title) > 0 && s.Flags.ShowTitle {
+		deck.TextMid(left+((right-left)/2), top+(linespacing*1.5), title, "sans", spacing, Titlecolor)
+	}
+	if s.Flags.ShowAxis {
+		s.yaxis(deck, left-spacing, mindata, maxdata)
+		s.xaxis(deck, bottom-(ts*2), xmin, xmax)
+	}
+
+	datacolor := s.Attributes.DataColor
+	var px, py float64
+	for i := range data {
+		xp := vmap(x[i], xmin, xmax, left, right)
+		yp := s.scale(y[i], mindata, maxdata, bottom, top)
+		color := datacolor
+		if len(data[i].Note) > 0 {
+			color = data[i].Note
+		}
+		if s.Flags.ShowLine && i > 0 {
+			deck.Line(px, py, xp, yp, s.Measures.LineWidth, datacolor)
+		}
+		dotsize := ts * 0.6
+		s.tooltip(deck, data[i].Label, y[i], 0, data[i].Note)
+		if maxsize > 0 {
+			// the area of the bubble is proportional to the size
+			dotsize = vmap(math.Sqrt(math.Abs(size[i])), 0, math.Sqrt(maxsize), ts*0.2, ts*4)
+			deck.Circle(xp, yp, dotsize, color, 60)
+		} else {
+			deck.Circle(xp, yp, dotsize, color)
+		}
+		if s.Flags.ShowValues {
+			deck.TextMid(xp, yp+(dotsize/2)+(ts/2), dformat(s.Attributes.DataFmt, y[i]), "sans", ts*0.75, s.Attributes.ValueColor)
+		}
+		px, py = xp, yp
+	}
+
+	if s.Flags.ShowRegressionLine {
+		point := func(x, y float64) (float64, float64) {
+			return vmap(x, xmin, xmax, left, right), s.scale(y, mindata, maxdata, bottom, top)
+		}
+		color := s.Attributes.RegressionLineColor
+		reg, ok := s.rline(deck, x, y, mindata, maxdata, point, color)
+		if ok && s.Flags.ShowRegressionStats {
+			ex, ey := point(xmax, reg.at(xmax))
+			deck.TextEnd(ex, ey+ts*0.6, reg.String(), "sans", ts*0.6, color)
+		}
+	}
+
+	if s.Flags.FullDeck {
+		deck.EndSlide()
+	}
+	return nil
+}